@@ -10,10 +10,20 @@
 
 #include "value.h"
 #include "context.h"
+#include "engine.h"
+#include "error.h"
+
+// Duplicate string, if not empty.
+static char *context_strdup(char *str) {
+	return (str != NULL && str[0] != '\0') ? strdup(str) : NULL;
+}
 
 engine_context *context_new() {
 	engine_context *context;
 
+	// Contexts may be created on any thread for thread-safe builds of PHP.
+	engine_thread_init();
+
 	// Initialize context.
 	context = malloc((sizeof(engine_context)));
 	if (context == NULL) {
@@ -21,19 +31,75 @@ engine_context *context_new() {
 		return NULL;
 	}
 
+	memset(context, 0, sizeof(engine_context));
+
+	#ifdef ZTS
+		context->thread_id = tsrm_thread_id();
+	#endif
+
+	errno = 0;
+	return context;
+}
+
+// Set request information for context, as used during request startup. Empty
+// strings are considered to be unset.
+void context_set_request(engine_context *context, char *method, char *uri, char *query, char *content_type, long content_length, char *cookies, char *path, int proto) {
+	context->request_method  = context_strdup(method);
+	context->request_uri     = context_strdup(uri);
+	context->query_string    = context_strdup(query);
+	context->content_type    = context_strdup(content_type);
+	context->content_length  = content_length;
+	context->cookie_data     = context_strdup(cookies);
+	context->path_translated = context_strdup(path);
+	context->proto_num       = proto;
+}
+
+// Free context and request information for context. Contexts are freed
+// separately from their requests being shut down, so that contexts remain
+// valid until no longer referenced by the engine.
+void context_free(engine_context *context) {
+	free(context->request_method);
+	free(context->request_uri);
+	free(context->query_string);
+	free(context->content_type);
+	free(context->cookie_data);
+	free(context->path_translated);
+	free(context);
+}
+
+void context_startup(engine_context *context) {
 	SG(server_context) = context;
 
+	// Request information is reset for every context, as it otherwise persists
+	// between requests.
+	SG(request_info).request_method  = context->request_method;
+	SG(request_info).request_uri     = context->request_uri;
+	SG(request_info).query_string    = context->query_string;
+	SG(request_info).content_type    = context->content_type;
+	SG(request_info).content_length  = context->content_length;
+	SG(request_info).path_translated = context->path_translated;
+	SG(request_info).proto_num       = context->proto_num;
+
 	// Initialize request lifecycle.
 	if (php_request_startup() == FAILURE) {
 		SG(server_context) = NULL;
-		free(context);
+		context_free(context);
 
 		errno = 1;
-		return NULL;
+		return;
 	}
 
+	error_activate();
+
+	context->response_code = &SG(sapi_headers).http_response_code;
+
+	// The interrupt flag is kept per thread for thread-safe builds of PHP, and
+	// is therefore captured for use by other threads.
+	#if PHP_VERSION_ID >= 70100
+		context->vm_interrupt = &EG(vm_interrupt);
+	#endif
+
 	errno = 0;
-	return context;
 }
 
 void context_exec(engine_context *context, char *filename) {
@@ -54,7 +120,72 @@ void context_exec(engine_context *context, char *filename) {
 		return;
 	} zend_end_try();
 
-	if (ret == FAILURE) {
+	// Bailing out on interrupt is handled by PHP during script execution, and
+	// does not otherwise result in a failure.
+	if (ret == FAILURE || context->interrupted) {
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+	return;
+}
+
+// Script data read by stream handles used for executing scripts from memory.
+typedef struct _context_data {
+	char *data;
+	size_t len;
+	size_t pos;
+} context_data;
+
+static size_t context_data_read(void *handle, char *buf, size_t len) {
+	context_data *d = (context_data *) handle;
+
+	size_t n = d->len - d->pos;
+	if (n > len) {
+		n = len;
+	}
+
+	memcpy(buf, d->data + d->pos, n);
+	d->pos += n;
+
+	return n;
+}
+
+static size_t context_data_size(void *handle) {
+	return ((context_data *) handle)->len;
+}
+
+// Execute script data given as a file with the name given. The data is read in
+// full before compilation, and the name is set as the opened path for the file,
+// as PHP would otherwise attempt to resolve it against the filesystem.
+void context_exec_data(engine_context *context, char *name, char *data, size_t len) {
+	context_data d = {data, len, 0};
+	int ret;
+
+	zend_first_try {
+		zend_file_handle script;
+		memset(&script, 0, sizeof(zend_file_handle));
+
+		script.type = ZEND_HANDLE_STREAM;
+		script.filename = name;
+		script.free_filename = 0;
+		script.handle.stream.handle = &d;
+		script.handle.stream.reader = context_data_read;
+		script.handle.stream.fsizer = context_data_size;
+		script.handle.stream.closer = NULL;
+
+		_context_set_opened_path(&script, name);
+
+		ret = php_execute_script(&script);
+	} zend_catch {
+		errno = 1;
+		return;
+	} zend_end_try();
+
+	// Bailing out on interrupt is handled by PHP during script execution, and
+	// does not otherwise result in a failure.
+	if (ret == FAILURE || context->interrupted) {
 		errno = 1;
 		return;
 	}
@@ -63,9 +194,9 @@ void context_exec(engine_context *context, char *filename) {
 	return;
 }
 
-void *context_eval(engine_context *context, char *script) {
+void *context_eval(engine_context *context, char *script, size_t len) {
 	zval *str = _value_init();
-	_value_set_string(&str, script);
+	_value_set_string(&str, script, len);
 
 	// Compile script value.
 	uint32_t compiler_options = CG(compiler_options);
@@ -78,13 +209,35 @@ void *context_eval(engine_context *context, char *script) {
 
 	// Return error if script failed to compile.
 	if (!op) {
+		error_exception_clear();
+
 		errno = 1;
 		return NULL;
 	}
 
-	// Attempt to execute compiled string.
+	// Attempt to execute compiled string, returning an error on fatal errors.
+	volatile int failed = 0;
 	zval tmp;
-	_context_eval(op, &tmp);
+
+	zend_try {
+		_context_eval(op, &tmp);
+	} zend_catch {
+		failed = 1;
+	} zend_end_try();
+
+	if (failed) {
+		errno = 1;
+		return NULL;
+	}
+
+	// Return error if script has thrown an uncaught exception.
+	if (EG(exception)) {
+		error_exception_clear();
+		zval_dtor(&tmp);
+
+		errno = 1;
+		return NULL;
+	}
 
 	// Allocate result value and copy temporary execution result in.
 	zval *result = malloc(sizeof(zval));
@@ -99,11 +252,101 @@ void context_bind(engine_context *context, char *name, void *value) {
 	_context_bind(name, v->internal);
 }
 
+// Set value for php.ini directive, for the lifetime of the context. Directives
+// changeable by scripts and per-directory directives are allowed, and original
+// values are restored on request shutdown.
+void context_set_ini(engine_context *context, char *name, char *value) {
+	if (_context_set_ini(name, value) == FAILURE) {
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+// Returns the context currently active in the engine, if any.
+engine_context *context_current() {
+	return (engine_context *) SG(server_context);
+}
+
+// Returns 1 if the context given was created on the current thread, 0 otherwise.
+// Contexts are always considered local for non thread-safe builds of PHP.
+int context_is_local(engine_context *context) {
+	#ifdef ZTS
+		return tsrm_thread_id() == context->thread_id;
+	#else
+		return 1;
+	#endif
+}
+
+#if PHP_VERSION_ID >= 70100
+// The VM interrupt handler in place before the engine was initialized, if any.
+static void (*context_interrupt_previous)(zend_execute_data *execute_data);
+
+// Abort execution for the active context if interrupted, as called by the VM
+// whenever the interrupt flag is set.
+static void context_interrupt_handler(zend_execute_data *execute_data) {
+	engine_context *context = SG(server_context);
+
+	if (context != NULL && context->interrupted) {
+		// Keep the interrupt flag set, so that any code executed after bailing out,
+		// such as shutdown functions, is aborted in turn.
+		EG(vm_interrupt) = 1;
+		zend_bailout();
+	}
+
+	if (context_interrupt_previous != NULL) {
+		context_interrupt_previous(execute_data);
+	}
+}
+#endif
+
+// Install VM interrupt handler used for aborting execution, where supported.
+void context_interrupt_init() {
+	#if PHP_VERSION_ID >= 70100
+		context_interrupt_previous = zend_interrupt_function;
+		zend_interrupt_function = context_interrupt_handler;
+	#endif
+}
+
+// Interrupt execution for the context, which may be running on a different
+// thread. Execution is aborted once the VM next checks for interrupts, and any
+// further execution is aborted until the interrupt is cleared. Interrupting
+// execution requires PHP 7.1 or later; errno is set for earlier versions.
+void context_interrupt(engine_context *context) {
+	#if PHP_VERSION_ID >= 70100
+		context->interrupted = 1;
+		*context->vm_interrupt = 1;
+
+		errno = 0;
+	#else
+		errno = 1;
+	#endif
+}
+
+// Clear any pending interrupt for the context.
+void context_interrupt_clear(engine_context *context) {
+	if (!context->interrupted) {
+		return;
+	}
+
+	context->interrupted = 0;
+
+	#if PHP_VERSION_ID >= 70100
+		*context->vm_interrupt = 0;
+	#endif
+}
+
+// Returns the response code set for the context, which may be running on a
+// different thread.
+int context_get_status(engine_context *context) {
+	return *context->response_code;
+}
+
 void context_destroy(engine_context *context) {
 	php_request_shutdown(NULL);
 
 	SG(server_context) = NULL;
-	free(context);
 }
 
 #include "_context.c"
@@ -10,12 +10,19 @@ package php
 // #include <stdlib.h>
 // #include <main/php.h>
 // #include "context.h"
+// #include "value.h"
 import "C"
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
+	"time"
 	"unsafe"
 )
 
@@ -30,8 +37,23 @@ type Context struct {
 	// Header represents the HTTP headers set by current PHP context.
 	Header http.Header
 
+	// ErrorHandler, if set, is called for every error raised by PHP during
+	// execution, including notices, warnings and deprecation messages, and
+	// regardless of the 'error_reporting' setting. If the handler returns true,
+	// the error is considered handled, and is neither displayed as part of the
+	// script output nor logged; otherwise, the error is passed on to PHP's
+	// standard error handling. Fatal errors halt execution regardless.
+	ErrorHandler func(err *Error) bool
+
 	context *C.struct__engine_context
 	values  []*Value
+	status  int
+	err     error
+	request *http.Request
+	server  map[string]string
+
+	// The worker process executing the context, for isolated engines only.
+	worker *isolatedWorker
 }
 
 // Bind allows for binding Go values into the current execution context under
@@ -39,6 +61,16 @@ type Context struct {
 // (check the documentation for NewValue for what is considered to be a "valid"
 // value).
 func (c *Context) Bind(name string, val interface{}) error {
+	if c.worker != nil {
+		v, err := portableValue(val)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.remote(msgBind, &wireMessage{Name: name, Value: v})
+		return err
+	}
+
 	v, err := NewValue(val)
 	if err != nil {
 		return err
@@ -56,27 +88,98 @@ func (c *Context) Bind(name string, val interface{}) error {
 // Exec executes a PHP script pointed to by filename in the current execution
 // context, and returns an error, if any. Output produced by the script is
 // written to the context's pre-defined io.Writer instance.
+//
+// Parse errors and fatal errors raised by the script are returned as *Error
+// values, while uncaught exceptions are returned as *Exception values.
 func (c *Context) Exec(filename string) error {
+	c.clearInterrupt()
+	return c.exec(filename)
+}
+
+func (c *Context) exec(filename string) error {
+	if c.worker != nil {
+		_, err := c.remote(msgExec, &wireMessage{Name: filename, Handler: c.ErrorHandler != nil})
+		return err
+	}
+
 	f := C.CString(filename)
 	defer C.free(unsafe.Pointer(f))
 
+	c.err = nil
+
 	_, err := C.context_exec(c.context, f)
-	if err != nil {
+	if c.err != nil {
+		return c.err
+	} else if err != nil {
 		return fmt.Errorf("Error executing script '%s' in context", filename)
 	}
 
 	return nil
 }
 
+// ExecReader executes the PHP script read from r in the current execution
+// context, in the same way as Exec. The script is compiled as a file, and may
+// therefore contain opening and closing PHP tags and inline HTML, unlike for
+// Eval. The name given is used as the script's file name (e.g. for '__FILE__'
+// and in errors raised by the script), and need not refer to an existing file.
+// Scripts held in memory can be executed with a bytes.Reader.
+func (c *Context) ExecReader(name string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Error reading script '%s': %s", name, err)
+	}
+
+	c.clearInterrupt()
+	return c.execData(name, data)
+}
+
+func (c *Context) execData(name string, data []byte) error {
+	if c.worker != nil {
+		_, err := c.remote(msgExecData, &wireMessage{Name: name, Data: data, Handler: c.ErrorHandler != nil})
+		return err
+	}
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	buf := C.CBytes(data)
+	defer C.free(buf)
+
+	c.err = nil
+
+	_, err := C.context_exec_data(c.context, n, (*C.char)(buf), C.size_t(len(data)))
+	if c.err != nil {
+		return c.err
+	} else if err != nil {
+		return fmt.Errorf("Error executing script '%s' in context", name)
+	}
+
+	return nil
+}
+
 // Eval executes the PHP expression contained in script, and returns a Value
 // containing the PHP value returned by the expression, if any. Any output
-// produced is written context's pre-defined io.Writer instance.
+// produced is written context's pre-defined io.Writer instance. Errors are
+// returned in the same way as for Exec.
 func (c *Context) Eval(script string) (*Value, error) {
+	c.clearInterrupt()
+	return c.eval(script)
+}
+
+func (c *Context) eval(script string) (*Value, error) {
+	if c.worker != nil {
+		return c.remoteValue(msgEval, &wireMessage{Name: script, Handler: c.ErrorHandler != nil})
+	}
+
 	s := C.CString(script)
 	defer C.free(unsafe.Pointer(s))
 
-	result, err := C.context_eval(c.context, s)
-	if err != nil {
+	c.err = nil
+
+	result, err := C.context_eval(c.context, s, C.size_t(len(script)))
+	if c.err != nil {
+		return nil, c.err
+	} else if err != nil {
 		return nil, fmt.Errorf("Error executing script '%s' in context", script)
 	}
 
@@ -92,9 +195,373 @@ func (c *Context) Eval(script string) (*Value, error) {
 	return val, nil
 }
 
+// ExecContext executes a PHP script pointed to by filename in the same way as
+// Exec, interrupting execution if ctx is cancelled or its deadline passes, in
+// which case an *InterruptError wrapping the error returned by ctx is returned.
+// Code executed during context shutdown, such as shutdown functions, is aborted
+// for interrupted contexts, which otherwise remain valid for further use.
+//
+// Interrupting execution requires PHP 7.1 or later; for earlier versions, the
+// script runs to completion regardless of ctx. Execution is only interrupted
+// between PHP instructions, and calls blocking within PHP, such as 'sleep' or
+// reads from streams, run to completion before execution is aborted. For
+// deadlines set on ctx, the 'default_socket_timeout' directive is lowered to
+// the time remaining for the duration of the call, where lower than the current
+// setting, so that blocking socket operations are limited accordingly.
+//
+// For isolated engines, the worker process executing the context is terminated
+// instead, including for any calls blocking within PHP, and the context is no
+// longer usable other than for being destroyed.
+func (c *Context) ExecContext(ctx context.Context, filename string) error {
+	return c.interruptible(ctx, func() error {
+		return c.exec(filename)
+	})
+}
+
+// EvalContext executes the PHP expression contained in script in the same way
+// as Eval, interrupting execution if ctx is cancelled or its deadline passes.
+// Errors are returned, and calls blocking within PHP are handled, in the same
+// way as for ExecContext.
+func (c *Context) EvalContext(ctx context.Context, script string) (*Value, error) {
+	var val *Value
+
+	err := c.interruptible(ctx, func() (err error) {
+		val, err = c.eval(script)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// Interruptible calls fn, interrupting any execution in progress for the context
+// if ctx is cancelled before fn returns.
+func (c *Context) interruptible(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return &InterruptError{Err: err}
+	}
+
+	c.clearInterrupt()
+
+	if deadline, ok := ctx.Deadline(); ok && c.worker == nil {
+		defer c.limitSocketTimeout(time.Until(deadline))()
+	}
+
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	interrupted := false
+
+	// Execution may only be interrupted while fn is running, as the context may
+	// otherwise be destroyed concurrently.
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			interrupted = c.interrupt()
+		case <-done:
+		}
+	}()
+
+	err := fn()
+
+	close(done)
+	<-stopped
+
+	// Execution aborted by the interrupt always results in an error, while
+	// execution that completed in the meantime returns as normal.
+	if !interrupted {
+		return err
+	} else if err != nil {
+		return &InterruptError{Err: ctx.Err()}
+	}
+
+	// Execution completed before being interrupted, and the pending interrupt is
+	// no longer needed.
+	c.clearInterrupt()
+
+	return nil
+}
+
+// LimitSocketTimeout lowers the 'default_socket_timeout' directive for the
+// context to the duration given, rounded up to the second, where lower than the
+// current setting, and returns a function restoring the previous setting.
+func (c *Context) limitSocketTimeout(d time.Duration) func() {
+	v, err := call(nil, "ini_get", []interface{}{"default_socket_timeout"})
+	if err != nil {
+		return func() {}
+	}
+
+	prev := v.String()
+	v.Destroy()
+
+	timeout := int64(math.Ceil(d.Seconds()))
+	if timeout < 1 {
+		timeout = 1
+	}
+
+	// Negative timeouts disable socket timeouts altogether, and are always
+	// lowered.
+	if n, err := strconv.ParseInt(prev, 10, 64); err == nil && n >= 0 && n <= timeout {
+		return func() {}
+	}
+
+	if err := c.SetIni("default_socket_timeout", strconv.FormatInt(timeout, 10)); err != nil {
+		return func() {}
+	}
+
+	return func() {
+		c.SetIni("default_socket_timeout", prev)
+	}
+}
+
+// Interrupt aborts any execution in progress for the context, and returns true
+// if execution could be interrupted.
+func (c *Context) interrupt() bool {
+	if c.worker != nil {
+		return c.worker.kill() == nil
+	}
+
+	_, err := C.context_interrupt(c.context)
+	return err == nil
+}
+
+// ClearInterrupt clears any pending interrupt for the context, as left over from
+// previous interrupted execution.
+func (c *Context) clearInterrupt() {
+	if c.context != nil {
+		C.context_interrupt_clear(c.context)
+	}
+}
+
+// Call calls the PHP function named, which may be either a built-in function
+// or a user-defined function declared in the current context, and returns a
+// Value containing the function's return value. Arguments are converted to PHP
+// values in the same way as for NewValue, and an error is returned if any of
+// them cannot be converted. Errors raised during the call, including uncaught
+// exceptions, are returned in the same way as for Exec.
+func (c *Context) Call(name string, args ...interface{}) (*Value, error) {
+	if c.worker != nil {
+		a, err := portableValue(args)
+		if err != nil {
+			return nil, err
+		}
+
+		list, _ := a.([]interface{})
+		return c.remoteValue(msgCall, &wireMessage{Name: name, Args: list, Handler: c.ErrorHandler != nil})
+	}
+
+	val, err := call(nil, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.values = append(c.values, val)
+
+	return val, nil
+}
+
+// SetIni sets the value for the php.ini directive named, for the lifetime of the
+// current context, in the same way as calling 'ini_set()' from PHP. Directives
+// that can otherwise only be changed per-directory (e.g. in '.htaccess' or
+// '.user.ini' files) can also be set. Original values are restored when the
+// context is destroyed. An error is returned if the directive does not exist,
+// cannot be changed at runtime, or if the value given is rejected.
+func (c *Context) SetIni(name, value string) error {
+	if c.worker != nil {
+		_, err := c.remote(msgSetIni, &wireMessage{Name: name, Value: value})
+		return err
+	}
+
+	n, v := C.CString(name), C.CString(value)
+	defer C.free(unsafe.Pointer(n))
+	defer C.free(unsafe.Pointer(v))
+
+	if _, err := C.context_set_ini(c.context, n, v); err != nil {
+		return fmt.Errorf("Failed to set ini directive '%s' in context", name)
+	}
+
+	return nil
+}
+
+// Status returns the HTTP response status code set by the current PHP context,
+// either explicitly (by calling 'http_response_code()' or 'header()' with a
+// status line) or implicitly (for instance, by setting a 'Location' header).
+// If no status code has been set, '200 OK' is assumed.
+//
+// Status reflects the final response status once the context is destroyed.
+func (c *Context) Status() int {
+	status := c.status
+	if c.context != nil {
+		status = int(C.context_get_status(c.context))
+	}
+
+	if status == 0 {
+		return http.StatusOK
+	}
+
+	return status
+}
+
+// New creates a new instance of the PHP class named, which is autoloaded if not
+// already declared, and returns it as an object Value. Arguments are passed to
+// the class constructor, if any, and are converted to PHP values in the same
+// way as for NewValue. Errors raised during construction, including uncaught
+// exceptions thrown by the constructor, are returned in the same way as for
+// Exec. Objects cannot be created in isolated contexts, for which an error is
+// returned.
+func (c *Context) New(class string, args ...interface{}) (*Value, error) {
+	if c.worker != nil {
+		return nil, fmt.Errorf("Cannot create instance of class '%s' in isolated context", class)
+	}
+
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	a, err := NewValue(args)
+	if err != nil {
+		return nil, err
+	}
+
+	defer a.Destroy()
+
+	n := C.CString(class)
+	defer C.free(unsafe.Pointer(n))
+
+	c.err = nil
+
+	result, err := C.value_object_new(n, a.value)
+	if c.err != nil {
+		return nil, c.err
+	} else if err != nil {
+		return nil, fmt.Errorf("Error creating instance of class '%s' in context", class)
+	}
+
+	val := &Value{value: result}
+	c.values = append(c.values, val)
+
+	return val, nil
+}
+
+// DefineConstant registers a PHP constant for the name passed, available for
+// the lifetime of the current context. Constant names and values follow the
+// same rules as for Engine.DefineConstant, and an error is returned if the
+// constant is already defined.
+func (c *Context) DefineConstant(name string, val interface{}) error {
+	if err := checkConstant(name, val); err != nil {
+		return err
+	}
+
+	if c.worker != nil {
+		v, err := portableValue(val)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.remote(msgDefineConstant, &wireMessage{Name: name, Value: v})
+		return err
+	}
+
+	v, err := call(nil, "define", []interface{}{name, val})
+	if err != nil {
+		return err
+	}
+
+	defer v.Destroy()
+
+	if !v.Bool() {
+		return fmt.Errorf("Failed to define constant '%s' in context", name)
+	}
+
+	return nil
+}
+
+// CheckConstant returns an error if the value given cannot be used as a value
+// for the constant named, which is limited to scalar and array values.
+func checkConstant(name string, val interface{}) error {
+	if name == "" {
+		return fmt.Errorf("Failed to define constant with empty name")
+	}
+
+	switch constantKind(val) {
+	case reflect.Invalid, reflect.Bool, reflect.String, reflect.Float64, reflect.Int64, reflect.Uint64,
+		reflect.Slice, reflect.Array, reflect.Map:
+		return nil
+	}
+
+	return fmt.Errorf("Failed to define constant '%s' of invalid type '%T'", name, val)
+}
+
+// CheckScalarConstant returns an error if the value given cannot be used as a
+// value for the persistent constant named, which is limited to scalar values.
+func checkScalarConstant(name string, val interface{}) error {
+	if name == "" {
+		return fmt.Errorf("Failed to define constant with empty name")
+	}
+
+	switch constantKind(val) {
+	case reflect.Invalid, reflect.Bool, reflect.String, reflect.Float64, reflect.Int64, reflect.Uint64:
+		return nil
+	}
+
+	return fmt.Errorf("Failed to define constant '%s' of invalid type '%T'", name, val)
+}
+
+// ConstantKind returns the kind of the value given, as converted by NewValue,
+// with pointers resolved to the values they point to. Nil and cyclic pointers
+// are returned as reflect.Invalid and reflect.Ptr respectively, byte slices as
+// reflect.String, and numeric kinds as the largest kind of the same class.
+func constantKind(val interface{}) reflect.Kind {
+	v := reflect.ValueOf(val)
+
+	visited := make(map[uintptr]bool)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		if visited[v.Pointer()] {
+			return reflect.Ptr
+		}
+
+		visited[v.Pointer()] = true
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return reflect.Invalid
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint64
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.String
+		}
+	}
+
+	return v.Kind()
+}
+
+// CurrentContext returns the context currently active in the engine, if any.
+func currentContext() *Context {
+	return engine.context(C.context_current())
+}
+
 // Destroy tears down the current execution context along with any active value
 // bindings for that context.
 func (c *Context) Destroy() {
+	if c.worker != nil {
+		c.destroyRemote()
+		return
+	}
+
 	if c.context == nil {
 		return
 	}
@@ -105,6 +572,24 @@ func (c *Context) Destroy() {
 
 	c.values = nil
 
-	C.context_destroy(c.context)
+	ptr := c.context
+	C.context_destroy(ptr)
+
 	c.context = nil
+
+	// Remove reference to context from the active engine, if any. This happens
+	// after the request shutdown, as output may still be produced until then,
+	// and before the context is freed, as the engine may inspect contexts it
+	// holds references to from other threads.
+	if engine != nil {
+		engine.mu.Lock()
+		delete(engine.contexts, ptr)
+		engine.mu.Unlock()
+	}
+
+	C.context_free(ptr)
+
+	if engine != nil {
+		engine.unlockContext()
+	}
 }
@@ -6,11 +6,16 @@ package php
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestContextStart(t *testing.T) {
@@ -31,6 +36,108 @@ func TestContextNew(t *testing.T) {
 	c.Destroy()
 }
 
+var requestTests = []struct {
+	method   string
+	target   string
+	header   http.Header
+	body     func(*multipart.Writer)
+	script   string
+	expected string
+}{
+	{
+		"GET",
+		"/test.php?hello=world&list[]=1&list[]=2",
+		nil,
+		nil,
+		"echo $_GET['hello'], count($_GET['list']), $_SERVER['REQUEST_METHOD'];",
+		"world2GET",
+	},
+	{
+		"GET",
+		"/test.php",
+		http.Header{"Cookie": []string{"hello=world; foo=bar"}, "X-Testing": []string{"Hello"}},
+		nil,
+		"echo $_COOKIE['hello'], $_COOKIE['foo'], $_SERVER['HTTP_X_TESTING'];",
+		"worldbarHello",
+	},
+	{
+		"GET",
+		"/test.php",
+		http.Header{"Proxy": []string{"http://proxy.invalid"}, "X-Testing": []string{"Hello"}},
+		nil,
+		"echo isset($_SERVER['HTTP_PROXY']) && $_SERVER['HTTP_PROXY'] == 'http://proxy.invalid' ? 'Proxy' : 'None', $_SERVER['HTTP_X_TESTING'];",
+		"NoneHello",
+	},
+	{
+		"POST",
+		"/test.php",
+		nil,
+		func(w *multipart.Writer) {
+			w.WriteField("hello", "world")
+
+			f, _ := w.CreateFormFile("upload", "hello.txt")
+			f.Write([]byte("Hello World"))
+		},
+		"echo $_POST['hello'], $_FILES['upload']['name'], file_get_contents($_FILES['upload']['tmp_name']);",
+		"worldhello.txtHello World",
+	},
+	{
+		"GET",
+		"/test.php",
+		nil,
+		nil,
+		"echo $_SERVER['SCRIPT_FILENAME'], $_SERVER['PHP_SELF'];",
+		"/tmp/test.php/test.php",
+	},
+}
+
+func TestContextNewFromRequest(t *testing.T) {
+	var w bytes.Buffer
+
+	for _, tt := range requestTests {
+		var body bytes.Buffer
+		var contentType string
+
+		if tt.body != nil {
+			mw := multipart.NewWriter(&body)
+			tt.body(mw)
+			mw.Close()
+
+			contentType = mw.FormDataContentType()
+		}
+
+		r := httptest.NewRequest(tt.method, tt.target, &body)
+		for k, v := range tt.header {
+			r.Header[k] = v
+		}
+
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+
+		c, err := e.NewContextFromRequest(r, map[string]string{"SCRIPT_FILENAME": "/tmp/test.php"})
+		if err != nil {
+			t.Errorf("NewContextFromRequest('%s'): %s", tt.target, err)
+			continue
+		}
+
+		c.Output = &w
+
+		if _, err := c.Eval(tt.script); err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+		}
+
+		actual := w.String()
+		w.Reset()
+
+		if actual != tt.expected {
+			t.Errorf("Context.Eval('%s'): Expected output '%s', actual '%s'", tt.script, tt.expected, actual)
+		}
+
+		c.Destroy()
+	}
+}
+
 var execTests = []struct {
 	name     string
 	script   string
@@ -79,6 +186,56 @@ func TestContextExec(t *testing.T) {
 	c.Destroy()
 }
 
+var execReaderTests = []struct {
+	name     string
+	script   string
+	expected string
+}{
+	{
+		"helloworld.php",
+		"<?php echo 'Hello World';",
+		"Hello World",
+	},
+	{
+		"templates/inline.php",
+		"<p><?= 'Hello' ?></p>\n<?php echo __FILE__;",
+		"<p>Hello</p>\ntemplates/inline.php",
+	},
+	{
+		"empty.php",
+		"",
+		"",
+	},
+}
+
+func TestContextExecReader(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	c.Output = &w
+
+	for _, tt := range execReaderTests {
+		if err := c.ExecReader(tt.name, strings.NewReader(tt.script)); err != nil {
+			t.Errorf("Context.ExecReader('%s'): Execution failed: %s", tt.name, err)
+			continue
+		}
+
+		actual := w.String()
+		w.Reset()
+
+		if actual != tt.expected {
+			t.Errorf("Context.ExecReader('%s'): Expected `%s', actual `%s'", tt.name, tt.expected, actual)
+		}
+	}
+
+	err := c.ExecReader("virtual/error.php", strings.NewReader("<?php\nthrow new Exception('Test');"))
+	if ex, ok := err.(*Exception); !ok || ex.File != "virtual/error.php" || ex.Line != 2 {
+		t.Errorf("Context.ExecReader(): Expected exception for 'virtual/error.php' on line 2, actual '%#v'", err)
+	}
+
+	c.Destroy()
+}
+
 var evalTests = []struct {
 	script string
 	output string
@@ -94,6 +251,11 @@ var evalTests = []struct {
 		"",
 		int64(30),
 	},
+	{
+		"return 'a\x00b';",
+		"",
+		"a\x00b",
+	},
 }
 
 func TestContextEval(t *testing.T) {
@@ -128,6 +290,348 @@ func TestContextEval(t *testing.T) {
 	c.Destroy()
 }
 
+func TestContextEvalContext(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	c.Output = &w
+
+	// Interrupting execution is only supported for PHP 7.1 and later.
+	if v, _ := c.Eval("return PHP_VERSION_ID;"); v.Int() < 70100 {
+		t.Skip("Interrupting execution is not supported for PHP versions prior to 7.1")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := c.EvalContext(ctx, "echo 'Start'; while (true) {}")
+	if ierr, ok := err.(*InterruptError); !ok {
+		t.Fatalf("Context.EvalContext(): Expected interrupt error, actual '%v'", err)
+	} else if ierr.Err != context.DeadlineExceeded || !ierr.Timeout() {
+		t.Errorf("Context.EvalContext(): Expected deadline error, actual '%v'", ierr.Err)
+	}
+
+	if w.String() != "Start" {
+		t.Errorf("Context.EvalContext(): Expected output 'Start', actual '%s'", w.String())
+	}
+
+	// Contexts remain usable after being interrupted.
+	val, err := c.EvalContext(context.Background(), "return 1 + 1;")
+	if err != nil {
+		t.Fatalf("Context.EvalContext(): %s", err)
+	} else if val.Int() != 2 {
+		t.Errorf("Context.EvalContext(): Expected '2', actual '%d'", val.Int())
+	}
+
+	if _, err := c.EvalContext(ctx, "return 1;"); err == nil {
+		t.Errorf("Context.EvalContext(): Expected error for expired context, got none")
+	}
+
+	if err := c.ExecContext(ctx, "nonexistent.php"); err == nil {
+		t.Errorf("Context.ExecContext(): Expected error for expired context, got none")
+	}
+
+	// Shutdown functions are aborted for interrupted contexts, and do not block
+	// the context from being destroyed.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	c.Eval("register_shutdown_function(function() { while (true) {} });")
+
+	if _, err := c.EvalContext(ctx, "while (true) {}"); err == nil {
+		t.Errorf("Context.EvalContext(): Expected error for cancelled context, got none")
+	}
+}
+
+func TestContextExecContext(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	c.Output = &w
+
+	// Interrupting execution is only supported for PHP 7.1 and later.
+	if v, _ := c.Eval("return PHP_VERSION_ID;"); v.Int() < 70100 {
+		t.Skip("Interrupting execution is not supported for PHP versions prior to 7.1")
+	}
+
+	script, err := NewScript("loop.php", "<?php echo 'Start'; while (true) {}")
+	if err != nil {
+		t.Fatalf("Could not create temporary file for testing: %s", err)
+	}
+
+	defer script.Remove()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = c.ExecContext(ctx, script.Name())
+	if ierr, ok := err.(*InterruptError); !ok {
+		t.Fatalf("Context.ExecContext(): Expected interrupt error, actual '%v'", err)
+	} else if ierr.Err != context.DeadlineExceeded || !ierr.Timeout() {
+		t.Errorf("Context.ExecContext(): Expected deadline error, actual '%v'", ierr.Err)
+	}
+
+	if w.String() != "Start" {
+		t.Errorf("Context.ExecContext(): Expected output 'Start', actual '%s'", w.String())
+	}
+}
+
+func TestContextSocketTimeout(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	c.SetIni("default_socket_timeout", "60")
+
+	// Socket timeouts are limited to the time remaining until the deadline for
+	// the duration of the call, as blocking calls are not interrupted.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	val, err := c.EvalContext(ctx, "return ini_get('default_socket_timeout');")
+	if err != nil {
+		t.Fatalf("Context.EvalContext(): %s", err)
+	} else if actual := val.String(); actual != "5" {
+		t.Errorf("Context.EvalContext(): Expected socket timeout '5', actual '%s'", actual)
+	}
+
+	val, err = c.Eval("return ini_get('default_socket_timeout');")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	} else if actual := val.String(); actual != "60" {
+		t.Errorf("Context.EvalContext(): Expected restored socket timeout '60', actual '%s'", actual)
+	}
+}
+
+var callTests = []struct {
+	name     string
+	args     []interface{}
+	expected interface{}
+}{
+	{"strtoupper", []interface{}{"hello"}, "HELLO"},
+	{"str_repeat", []interface{}{"a", 3}, "aaa"},
+	{"add", []interface{}{1, 2}, int64(3)},
+	{"join", []interface{}{",", []string{"a", "b"}}, "a,b"},
+	{"keys", []interface{}{map[string]int{"a": 1}}, []interface{}{"a"}},
+	{"none", nil, nil},
+}
+
+func TestContextCall(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	c.Output = &w
+
+	script := `function add($a, $b) { return $a + $b; }
+	function keys(array $a) { return array_keys($a); }
+	function none() { echo 'Called'; }
+	function fail() { throw new RuntimeException('Failed'); }`
+
+	if _, err := c.Eval(script); err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	for _, tt := range callTests {
+		val, err := c.Call(tt.name, tt.args...)
+		if err != nil {
+			t.Errorf("Context.Call('%s'): %s", tt.name, err)
+			continue
+		}
+
+		if actual := val.Interface(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Context.Call('%s'): Expected value '%#v', actual '%#v'", tt.name, tt.expected, actual)
+		}
+	}
+
+	if w.String() != "Called" {
+		t.Errorf("Context.Call('none'): Expected output 'Called', actual '%s'", w.String())
+	}
+
+	if _, err := c.Call("fail"); err == nil {
+		t.Errorf("Context.Call('fail'): Expected error, got none")
+	} else if ex, ok := err.(*Exception); !ok || ex.Class != "RuntimeException" || ex.Message != "Failed" {
+		t.Errorf("Context.Call('fail'): Expected *Exception, actual '%#v'", err)
+	}
+
+	if _, err := c.Call("does_not_exist"); err == nil {
+		t.Errorf("Context.Call('does_not_exist'): Expected error, got none")
+	}
+
+	if _, err := c.Call("add", func() {}); err == nil {
+		t.Errorf("Context.Call('add'): Expected error for invalid argument, got none")
+	}
+
+	c.Destroy()
+}
+
+func TestContextNewObject(t *testing.T) {
+	c, _ := e.NewContext()
+
+	script := `spl_autoload_register(function ($class) {
+		if ($class === 'Autoloaded') {
+			eval('class Autoloaded { public $loaded = true; }');
+		}
+	});
+	class Point {
+		public $x, $y;
+		public function __construct($x, $y = 0) {
+			if ($x < 0) throw new InvalidArgumentException('Negative');
+			$this->x = $x; $this->y = $y;
+		}
+	}`
+
+	if _, err := c.Eval(script); err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	obj, err := c.New("Point", 1, 2)
+	if err != nil {
+		t.Fatalf("Context.New('Point'): %s", err)
+	}
+
+	expected := map[string]interface{}{"x": int64(1), "y": int64(2)}
+	if actual := obj.Map(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Context.New('Point'): Expected '%#v', actual '%#v'", expected, actual)
+	}
+
+	obj, err = c.New("Autoloaded")
+	if err != nil {
+		t.Fatalf("Context.New('Autoloaded'): %s", err)
+	}
+
+	if actual := obj.Map(); !reflect.DeepEqual(actual, map[string]interface{}{"loaded": true}) {
+		t.Errorf("Context.New('Autoloaded'): Expected autoloaded object, actual '%#v'", actual)
+	}
+
+	if _, err := c.New("Point", -1); err == nil {
+		t.Errorf("Context.New('Point'): Expected error, got none")
+	} else if ex, ok := err.(*Exception); !ok || ex.Class != "InvalidArgumentException" {
+		t.Errorf("Context.New('Point'): Expected *Exception, actual '%#v'", err)
+	}
+
+	if _, err := c.New("DoesNotExist"); err == nil {
+		t.Errorf("Context.New('DoesNotExist'): Expected error, got none")
+	}
+
+	c.Destroy()
+}
+
+var constantTests = []struct {
+	name     string
+	value    interface{}
+	expected string
+}{
+	{"TEST_STRING", "Hello", `string(5) "Hello"`},
+	{"TEST_INT", 42, "int(42)"},
+	{"TEST_BOOL", true, "bool(true)"},
+	{"TEST_NULL", nil, "NULL"},
+	{`Test\Namespaced\VALUE`, 1.5, "float(1.5)"},
+	{"TEST_UINT", uint8(42), "int(42)"},
+	{"TEST_POINTER", func() *int { n := 42; return &n }(), "int(42)"},
+	{"TEST_SLICE", []string{"a"}, `array(1) {
+  [0]=>
+  string(1) "a"
+}`},
+}
+
+func TestContextDefineConstant(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	c.Output = &w
+
+	for _, tt := range constantTests {
+		if err := c.DefineConstant(tt.name, tt.value); err != nil {
+			t.Errorf("Context.DefineConstant('%s'): %s", tt.name, err)
+			continue
+		}
+
+		if _, err := c.Eval("var_dump(" + tt.name + ");"); err != nil {
+			t.Errorf("Context.Eval(): %s", err)
+			continue
+		}
+
+		actual := strings.TrimSpace(w.String())
+		w.Reset()
+
+		if actual != tt.expected {
+			t.Errorf("Context.DefineConstant('%s'): Expected '%s', actual '%s'", tt.name, tt.expected, actual)
+		}
+	}
+
+	if err := c.DefineConstant("TEST_INT", 1); err == nil {
+		t.Errorf("Context.DefineConstant('TEST_INT'): Incorrectly defined duplicate constant")
+	}
+
+	if err := c.DefineConstant("TEST_FUNC", func() {}); err == nil {
+		t.Errorf("Context.DefineConstant('TEST_FUNC'): Incorrectly defined constant of invalid type")
+	}
+
+	c.Destroy()
+
+	// Constants defined in a context are not available in subsequent contexts,
+	// while constants defined for the engine are available to all contexts.
+	if err := e.DefineConstant("TEST_ENGINE", "Engine"); err != nil {
+		t.Fatalf("Engine.DefineConstant('TEST_ENGINE'): %s", err)
+	}
+
+	if err := e.DefineConstant("TEST_ENGINE_ARRAY", []string{"Engine", "Array"}); err != nil {
+		t.Fatalf("Engine.DefineConstant('TEST_ENGINE_ARRAY'): %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		c, _ = e.NewContext()
+		c.Output = &w
+
+		if _, err := c.Eval("echo TEST_ENGINE, ':', implode(',', TEST_ENGINE_ARRAY), ':', defined('TEST_STRING') ? 'defined' : 'undefined';"); err != nil {
+			t.Errorf("Context.Eval(): %s", err)
+		}
+
+		c.Destroy()
+
+		if actual := w.String(); actual != "Engine:Engine,Array:undefined" {
+			t.Errorf("Engine.DefineConstant('TEST_ENGINE'): Expected 'Engine:Engine,Array:undefined', actual '%s'", actual)
+		}
+
+		w.Reset()
+	}
+}
+
+func TestContextSetIni(t *testing.T) {
+	c, _ := e.NewContext()
+
+	if err := c.SetIni("precision", "3"); err != nil {
+		t.Errorf("Context.SetIni('precision'): %s", err)
+	}
+
+	if val, _ := c.Eval("return ini_get('precision');"); val.String() != "3" {
+		t.Errorf("Context.SetIni('precision'): Expected value '3', actual '%s'", val.String())
+	}
+
+	// Per-directory directives cannot be set using 'ini_set()', but can be set
+	// for contexts.
+	if err := c.SetIni("short_open_tag", "0"); err != nil {
+		t.Errorf("Context.SetIni('short_open_tag'): %s", err)
+	}
+
+	if err := c.SetIni("nonexistent.directive", "1"); err == nil {
+		t.Errorf("Context.SetIni('nonexistent.directive'): Incorrectly set non-existent directive")
+	}
+
+	c.Destroy()
+
+	// Directives are reset for subsequent contexts.
+	c, _ = e.NewContext()
+	defer c.Destroy()
+
+	if val, _ := c.Eval("return ini_get('precision');"); val.String() == "3" {
+		t.Errorf("Context.SetIni('precision'): Value was not reset for new context")
+	}
+}
+
 var headerTests = []struct {
 	script   string
 	expected http.Header
@@ -167,6 +671,81 @@ func TestContextHeader(t *testing.T) {
 	c.Destroy()
 }
 
+var statusTests = []struct {
+	script   string
+	expected int
+}{
+	{
+		"echo 'Hello World';",
+		http.StatusOK,
+	},
+	{
+		"http_response_code(404);",
+		http.StatusNotFound,
+	},
+	{
+		"header('HTTP/1.1 503 Service Unavailable');",
+		http.StatusServiceUnavailable,
+	},
+	{
+		"header('Location: /redirect');",
+		http.StatusFound,
+	},
+	{
+		"header('Location: /redirect', true, 301);",
+		http.StatusMovedPermanently,
+	},
+}
+
+func TestContextStatus(t *testing.T) {
+	for _, tt := range statusTests {
+		c, _ := e.NewContext()
+
+		if _, err := c.Eval(tt.script); err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			c.Destroy()
+			continue
+		}
+
+		if actual := c.Status(); actual != tt.expected {
+			t.Errorf("Context.Status('%s'): expected '%d', actual '%d'", tt.script, tt.expected, actual)
+		}
+
+		c.Destroy()
+
+		if actual := c.Status(); actual != tt.expected {
+			t.Errorf("Context.Status('%s'): expected '%d' after destroy, actual '%d'", tt.script, tt.expected, actual)
+		}
+	}
+
+	if !ThreadSafe() {
+		return
+	}
+
+	// The status for contexts created on other threads is that of the context,
+	// rather than that of the current thread.
+	ready, done := make(chan *Context), make(chan bool)
+
+	go func() {
+		c, _ := e.NewContext()
+		c.Eval("http_response_code(404);")
+
+		ready <- c
+		<-done
+
+		c.Destroy()
+		done <- true
+	}()
+
+	c := <-ready
+	if actual := c.Status(); actual != http.StatusNotFound {
+		t.Errorf("Context.Status(): expected '%d' for other thread, actual '%d'", http.StatusNotFound, actual)
+	}
+
+	done <- true
+	<-done
+}
+
 var logTests = []struct {
 	script   string
 	expected string
@@ -283,6 +862,58 @@ func TestContextBind(t *testing.T) {
 	c.Destroy()
 }
 
+func TestContextConcurrent(t *testing.T) {
+	if !ThreadSafe() {
+		t.Skip("Concurrent execution requires thread-safe (ZTS) build of PHP")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			var w bytes.Buffer
+
+			c, err := e.NewContext()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			defer c.Destroy()
+
+			c.Output = &w
+
+			if err := c.Bind("n", i); err != nil {
+				errs <- err
+				return
+			}
+
+			for j := 0; j < 100; j++ {
+				if _, err := c.Eval("echo $n;"); err != nil {
+					errs <- err
+					return
+				}
+			}
+
+			if expected := strings.Repeat(fmt.Sprintf("%d", i), 100); w.String() != expected {
+				errs <- fmt.Errorf("Expected output '%s', actual '%s'", expected, w.String())
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Context.Eval(): %s", err)
+	}
+}
+
 func TestContextDestroy(t *testing.T) {
 	c, _ := e.NewContext()
 	c.Destroy()
@@ -0,0 +1,370 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Decode stores the internal PHP value in the Go value pointed to by dst, which
+// must be a non-nil pointer. Values are decoded in the same way as they would
+// be returned by Interface, and then converted to the type pointed to by dst,
+// allocating pointers, slices and maps as required:
+//
+//	integer, double       -> any numeric type, if representable
+//	string                -> string, []byte
+//	boolean               -> bool
+//	indexed array         -> slice, array, map, struct
+//	associative array     -> map, struct
+//	object                -> map, struct, *ObjectValue
+//	null                  -> zero value
+//
+// Struct fields are matched against array keys or object properties by name,
+// preferring an exact match to a case-insensitive one. Field names can be
+// changed with a `php:"name"` struct tag, and fields tagged with `php:"-"`
+// are ignored, as are unexported fields. Fields tagged with `php:",inline"`
+// are decoded from the same array or object as the containing struct, while
+// the `omitempty` option has no effect when decoding. Values decoded into
+// interface{} types are stored as-is, and types implementing Unmarshaler are
+// passed the value as it would be returned by Interface.
+//
+// Objects decoded into interface{} or *ObjectValue types keep their handle to
+// the live PHP object, which should be released with ObjectValue.Destroy once
+// no longer in use. Handles are released for objects decoded into maps or
+// structs, including for any objects contained in their properties, as well as
+// for all objects in the value if decoding fails.
+//
+// Errors returned for values that cannot be converted contain the path to the
+// offending value, e.g. 'Items[2].Name'.
+func (v *Value) Decode(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Cannot decode value into non-pointer type '%T'", dst)
+	}
+
+	src := v.Interface()
+	if err := decodeValue(src, rv.Elem(), ""); err != nil {
+		ReleaseObjects(src)
+		return err
+	}
+
+	return nil
+}
+
+// DecodeValue stores the value src, as returned by Value.Interface, in the Go
+// value dst, which must be settable. The path given denotes the location of
+// src in the value being decoded, and is used for reporting errors.
+func decodeValue(src interface{}, dst reflect.Value, path string) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() != reflect.Ptr && dst.CanAddr() {
+		if u, ok := dst.Addr().Interface().(Unmarshaler); ok {
+			return u.UnmarshalPHP(src)
+		}
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+
+		return decodeValue(src, dst.Elem(), path)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+
+		switch s := src.(type) {
+		case int64:
+			n = s
+		case float64:
+			if s != math.Trunc(s) || s < math.MinInt64 || s >= math.MaxInt64 {
+				return decodeError(src, dst, path)
+			}
+
+			n = int64(s)
+		default:
+			return decodeError(src, dst, path)
+		}
+
+		if dst.OverflowInt(n) {
+			return decodeError(src, dst, path)
+		}
+
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+
+		switch s := src.(type) {
+		case int64:
+			if s < 0 {
+				return decodeError(src, dst, path)
+			}
+
+			n = uint64(s)
+		case float64:
+			if s != math.Trunc(s) || s < 0 || s >= math.MaxUint64 {
+				return decodeError(src, dst, path)
+			}
+
+			n = uint64(s)
+		default:
+			return decodeError(src, dst, path)
+		}
+
+		if dst.OverflowUint(n) {
+			return decodeError(src, dst, path)
+		}
+
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		var n float64
+
+		switch s := src.(type) {
+		case int64:
+			n = float64(s)
+		case float64:
+			n = s
+		default:
+			return decodeError(src, dst, path)
+		}
+
+		if dst.OverflowFloat(n) {
+			return decodeError(src, dst, path)
+		}
+
+		dst.SetFloat(n)
+		return nil
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			break
+		}
+
+		dst.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			break
+		}
+
+		dst.SetString(s)
+		return nil
+	case reflect.Slice:
+		switch s := src.(type) {
+		case string:
+			if dst.Type().Elem().Kind() != reflect.Uint8 {
+				break
+			}
+
+			dst.SetBytes([]byte(s))
+			return nil
+		case []interface{}:
+			d := reflect.MakeSlice(dst.Type(), len(s), len(s))
+			for i := range s {
+				if err := decodeValue(s[i], d.Index(i), decodeIndexPath(path, i)); err != nil {
+					return err
+				}
+			}
+
+			dst.Set(d)
+			return nil
+		}
+	case reflect.Array:
+		s, ok := src.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < dst.Len(); i++ {
+			if i >= len(s) {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+				continue
+			}
+
+			if err := decodeValue(s[i], dst.Index(i), decodeIndexPath(path, i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case reflect.Map:
+		m, ok := decodeMap(src)
+		if !ok {
+			break
+		}
+
+		d := reflect.MakeMap(dst.Type())
+		kt, et := dst.Type().Key(), dst.Type().Elem()
+
+		for k, e := range m {
+			key := reflect.New(kt).Elem()
+			if err := decodeKey(k, key); err != nil {
+				return fmt.Errorf("Cannot decode key '%s' into type '%s' at '%s'", k, kt, decodePath(path))
+			}
+
+			val := reflect.New(et).Elem()
+			if err := decodeValue(e, val, decodeKeyPath(path, k)); err != nil {
+				return err
+			}
+
+			d.SetMapIndex(key, val)
+		}
+
+		dst.Set(d)
+		return nil
+	case reflect.Struct:
+		m, ok := decodeMap(src)
+		if !ok {
+			break
+		}
+
+		// Sort keys for deterministic matching of case-insensitive field names.
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		dt := dst.Type()
+		for i := 0; i < dt.NumField(); i++ {
+			f := dt.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+
+			tag := fieldTag(f)
+			if tag.name == "-" {
+				continue
+			} else if tag.inline {
+				if err := decodeValue(m, dst.Field(i), path); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			name := tag.name
+			e, ok := m[name]
+			if !ok {
+				for _, k := range keys {
+					if strings.EqualFold(k, name) {
+						e, ok = m[k], true
+						break
+					}
+				}
+			}
+
+			if !ok {
+				continue
+			}
+
+			if err := decodeValue(e, dst.Field(i), decodeFieldPath(path, name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return decodeError(src, dst, path)
+}
+
+// DecodeMap returns the array or object value src as a map, with indexed arrays
+// converted to maps keyed by each element's index. Handles for objects, and for
+// any objects contained in their properties, are released, as objects decoded
+// into maps or structs are not retained.
+func decodeMap(src interface{}) (map[string]interface{}, bool) {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		return s, true
+	case *ObjectValue:
+		ReleaseObjects(s)
+		return s.Map(), true
+	case []interface{}:
+		m := make(map[string]interface{}, len(s))
+		for i := range s {
+			m[strconv.Itoa(i)] = s[i]
+		}
+
+		return m, true
+	}
+
+	return nil, false
+}
+
+// DecodeKey stores the array key given in the Go value dst, which must be a map
+// key of string or integer type.
+func decodeKey(key string, dst reflect.Value) error {
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(key, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		dst.SetUint(n)
+	default:
+		return fmt.Errorf("Unsupported key type '%s'", dst.Type())
+	}
+
+	return nil
+}
+
+// DecodeError returns an error for the value src that cannot be decoded into
+// dst, at the path given.
+func decodeError(src interface{}, dst reflect.Value, path string) error {
+	return fmt.Errorf("Cannot decode value of type '%T' into type '%s' at '%s'", src, dst.Type(), decodePath(path))
+}
+
+func decodePath(path string) string {
+	if path == "" {
+		return "."
+	}
+
+	return path
+}
+
+func decodeIndexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func decodeKeyPath(path, key string) string {
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+func decodeFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
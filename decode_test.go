@@ -0,0 +1,223 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+type decodeExtra struct {
+	Ref testID `php:"ref"`
+}
+
+type decodeItem struct {
+	Name  string
+	Price float64
+	Tags  []string `php:"labels,omitempty"`
+}
+
+type decodeOrder struct {
+	Extra decodeExtra `php:",inline"`
+
+	ID      int `php:"id"`
+	Items   []decodeItem
+	Meta    map[string]int
+	Count   *uint8
+	Ignored string `php:"-"`
+	hidden  string
+}
+
+var decodeTests = []struct {
+	script   string
+	dst      interface{}
+	expected interface{}
+}{
+	{
+		"return 42;",
+		new(int32),
+		int32(42),
+	},
+	{
+		"class DecodePoint { public $x = 1; protected $y = 2; private $z = 3; } return new DecodePoint;",
+		new(struct{ X, Y, Z int }),
+		struct{ X, Y, Z int }{1, 2, 3},
+	},
+	{
+		"return 2.0;",
+		new(uint),
+		uint(2),
+	},
+	{
+		"return 42;",
+		new(float32),
+		float32(42),
+	},
+	{
+		"return 'Hello World';",
+		new([]byte),
+		[]byte("Hello World"),
+	},
+	{
+		"return null;",
+		new(*int),
+		(*int)(nil),
+	},
+	{
+		"return [1, 2, 3];",
+		new([]int),
+		[]int{1, 2, 3},
+	},
+	{
+		"return [1, 2];",
+		new([3]int),
+		[3]int{1, 2, 0},
+	},
+	{
+		"return ['a', 'b'];",
+		new(map[int]string),
+		map[int]string{0: "a", 1: "b"},
+	},
+	{
+		"return [10 => true, 20 => false];",
+		new(map[uint]bool),
+		map[uint]bool{10: true, 20: false},
+	},
+	{
+		"return ['x' => [1, 'y'], 'z' => null];",
+		new(map[string]interface{}),
+		map[string]interface{}{"x": []interface{}{int64(1), "y"}, "z": nil},
+	},
+	{
+		`$o = new stdClass;
+		 $o->id = 7;
+		 $o->items = [['name' => 'Apple', 'price' => 1.5, 'labels' => ['fruit']], ['Name' => 'Pear', 'Price' => 2]];
+		 $o->Meta = ['stock' => 3];
+		 $o->Count = 5;
+		 $o->Ignored = 'set';
+		 $o->hidden = 'set';
+		 $o->ref = 'id-12';
+		 return $o;`,
+		new(decodeOrder),
+		decodeOrder{
+			Extra: decodeExtra{Ref: 12},
+			ID:    7,
+			Items: []decodeItem{{"Apple", 1.5, []string{"fruit"}}, {"Pear", 2, nil}},
+			Meta:  map[string]int{"stock": 3},
+			Count: func(n uint8) *uint8 { return &n }(5),
+		},
+	},
+}
+
+func TestDecode(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	for _, tt := range decodeTests {
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			continue
+		}
+
+		if err := val.Decode(tt.dst); err != nil {
+			t.Errorf("Value.Decode('%s'): %s", tt.script, err)
+			val.Destroy()
+			continue
+		}
+
+		actual := reflect.ValueOf(tt.dst).Elem().Interface()
+
+		if reflect.DeepEqual(actual, tt.expected) == false {
+			t.Errorf("Value.Decode('%s'): expected '%#v', actual '%#v'", tt.script, tt.expected, actual)
+		}
+
+		val.Destroy()
+	}
+}
+
+var decodeErrorTests = []struct {
+	script   string
+	dst      interface{}
+	expected string
+}{
+	{
+		"return 'Hello';",
+		new(int),
+		"Cannot decode value of type 'string' into type 'int' at '.'",
+	},
+	{
+		"return 300;",
+		new(uint8),
+		"Cannot decode value of type 'int64' into type 'uint8' at '.'",
+	},
+	{
+		"return -1;",
+		new(uint),
+		"Cannot decode value of type 'int64' into type 'uint' at '.'",
+	},
+	{
+		"return 1.5;",
+		new(int),
+		"Cannot decode value of type 'float64' into type 'int' at '.'",
+	},
+	{
+		"return ['a' => 1];",
+		new(map[int]int),
+		"Cannot decode key 'a' into type 'int' at '.'",
+	},
+	{
+		"return ['items' => [['name' => 'Apple'], ['name' => 'Pear', 'price' => 'free']]];",
+		new(decodeOrder),
+		"Cannot decode value of type 'string' into type 'float64' at 'Items[1].Price'",
+	},
+	{
+		"return ['Meta' => ['stock' => true]];",
+		new(decodeOrder),
+		"Cannot decode value of type 'bool' into type 'int' at 'Meta[\"stock\"]'",
+	},
+	{
+		"return 42;",
+		42,
+		"Cannot decode value into non-pointer type 'int'",
+	},
+	{
+		"return 42;",
+		new(testID),
+		"Invalid ID '42'",
+	},
+}
+
+func TestDecodeError(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	for _, tt := range decodeErrorTests {
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			continue
+		}
+
+		err = val.Decode(tt.dst)
+		if err == nil {
+			t.Errorf("Value.Decode('%s'): Value is invalid but no error occured", tt.script)
+		} else if err.Error() != tt.expected {
+			t.Errorf("Value.Decode('%s'): expected error '%s', actual '%s'", tt.script, tt.expected, err)
+		}
+
+		val.Destroy()
+	}
+}
+
+func TestDecodeEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
@@ -4,6 +4,7 @@
 
 #include <stdio.h>
 #include <errno.h>
+#include <stdbool.h>
 
 #include <main/php.h>
 #include <main/SAPI.h>
@@ -11,6 +12,10 @@
 #include <main/php_variables.h>
 
 #include "context.h"
+#include "error.h"
+#include "value.h"
+#include "module.h"
+#include "mount.h"
 #include "engine.h"
 #include "_cgo_export.h"
 
@@ -51,16 +56,44 @@ static int engine_header_handler(sapi_header_struct *sapi_header, sapi_header_op
 	return 0;
 }
 
+static int engine_send_headers(sapi_headers_struct *sapi_headers) {
+	engine_context *context = SG(server_context);
+
+	// Headers are handled as they are set, so only the response code is needed.
+	engineSetStatus(context, sapi_headers->http_response_code);
+
+	return SAPI_HEADER_SENT_SUCCESSFULLY;
+}
+
 static void engine_send_header(sapi_header_struct *sapi_header, void *server_context) {
 	// Do nothing.
 }
 
+static int engine_read_post(char *buffer, uint count_bytes) {
+	engine_context *context = SG(server_context);
+
+	return engineReadPost(context, (void *) buffer, count_bytes);
+}
+
 static char *engine_read_cookies() {
-	return NULL;
+	engine_context *context = SG(server_context);
+
+	if (context == NULL) {
+		return NULL;
+	}
+
+	return context->cookie_data;
 }
 
 static void engine_register_variables(zval *track_vars_array) {
+	engine_context *context = SG(server_context);
+
 	php_import_environment_variables(track_vars_array);
+	engineRegisterVariables(context, (void *) track_vars_array);
+}
+
+void engine_register_variable(char *key, char *value, void *track_vars_array) {
+	php_register_variable_safe(key, value, strlen(value), (zval *) track_vars_array);
 }
 
 #if PHP_VERSION_ID < 70100
@@ -91,10 +124,10 @@ static sapi_module_struct engine_module = {
 	php_error,                   // Error Handler
 
 	engine_header_handler,       // Header Handler
-	NULL,                        // Send Headers Handler
+	engine_send_headers,         // Send Headers Handler
 	engine_send_header,          // Send Header Handler
 
-	NULL,                        // Read POST Data
+	_engine_read_post,           // Read POST Data
 	engine_read_cookies,         // Read Cookies
 
 	engine_register_variables,   // Register Server Variables
@@ -105,7 +138,9 @@ static sapi_module_struct engine_module = {
 	STANDARD_SAPI_MODULE_PROPERTIES
 };
 
-php_engine *engine_init(void) {
+// Initialize engine, using the php.ini directives given in addition to, and with
+// precedence over, the engine defaults.
+php_engine *engine_init(char *ini_entries) {
 	php_engine *engine;
 
 	#ifdef HAVE_SIGNAL_H
@@ -114,18 +149,39 @@ php_engine *engine_init(void) {
 		#endif
 	#endif
 
+	#ifdef ZTS
+		tsrm_startup(1, 1, 0, NULL);
+		(void) ts_resource(0);
+		ZEND_TSRMLS_CACHE_UPDATE();
+	#endif
+
 	sapi_startup(&engine_module);
 
-	engine_module.ini_entries = malloc(sizeof(engine_ini_defaults));
-	memcpy(engine_module.ini_entries, engine_ini_defaults, sizeof(engine_ini_defaults));
+	size_t defaults_len = strlen(engine_ini_defaults), entries_len = strlen(ini_entries);
+
+	// Directives given are appended to the defaults, and take precedence over
+	// these as they are parsed later.
+	engine_module.ini_entries = malloc(defaults_len + entries_len + 1);
+	memcpy(engine_module.ini_entries, engine_ini_defaults, defaults_len);
+	memcpy(engine_module.ini_entries + defaults_len, ini_entries, entries_len + 1);
+
+	// Modules defined for the engine are registered along with built-in modules.
+	unsigned int module_count;
+	zend_module_entry *modules = module_entries(&module_count);
 
-	if (php_module_startup(&engine_module, NULL, 0) == FAILURE) {
+	if (php_module_startup(&engine_module, modules, module_count) == FAILURE) {
 		sapi_shutdown();
+		module_free_all();
+		free(engine_module.ini_entries);
 
 		errno = 1;
 		return NULL;
 	}
 
+	error_init();
+	context_interrupt_init();
+	mount_init();
+
 	engine = malloc((sizeof(php_engine)));
 
 	errno = 0;
@@ -133,11 +189,53 @@ php_engine *engine_init(void) {
 }
 
 void engine_shutdown(php_engine *engine) {
+	engine_thread_init();
+	error_shutdown();
+
 	php_module_shutdown();
 	sapi_shutdown();
+	module_free_all();
+
+	#ifdef ZTS
+		tsrm_shutdown();
+	#endif
 
 	free(engine_module.ini_entries);
 	free(engine);
 }
 
+// Allocate PHP resources for the current thread, if not already allocated. This
+// is required before calling into PHP from any thread other than the one the
+// engine was initialized on, and is a no-op for non thread-safe builds of PHP.
+void engine_thread_init(void) {
+	#ifdef ZTS
+		(void) ts_resource(0);
+	#endif
+}
+
+// Returns an indexed array of loaded PHP modules and Zend extensions, each given
+// as an array containing the name, the version, and whether or not the entry
+// represents a Zend extension.
+engine_value *engine_extensions(void) {
+	engine_value *list;
+
+	engine_thread_init();
+
+	list = value_new();
+	value_set_array(list, zend_hash_num_elements(&module_registry));
+
+	_engine_extensions(list->internal);
+
+	return list;
+}
+
+// Returns 1 if PHP has been built with thread-safety (ZTS) enabled, 0 otherwise.
+int engine_thread_safe(void) {
+	#ifdef ZTS
+		return 1;
+	#else
+		return 0;
+	#endif
+}
+
 #include "_engine.c"
@@ -11,16 +11,28 @@ package php
 //
 // #include <stdlib.h>
 // #include <main/php.h>
-// #include "receiver.h"
 // #include "context.h"
+// #include "value.h"
+// #include "receiver.h"
 // #include "engine.h"
+// #include "function.h"
+// #include "module.h"
+// #include "mount.h"
 import "C"
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"unsafe"
 )
 
@@ -29,38 +41,251 @@ type Engine struct {
 	engine    *C.struct__php_engine
 	contexts  map[*C.struct__engine_context]*Context
 	receivers map[string]*Receiver
+	functions map[string]reflect.Value
+	constants map[string]interface{}
+	modules   map[string]*Module
+	mounts    map[string]mountFS
+
+	// The scheme for the filesystem mounted for includes, if any.
+	includeMount string
+
+	// The configuration the engine was created with, as passed on to worker
+	// processes.
+	config Config
+
+	// Guards access to the engine's contexts and definitions, which may be used
+	// concurrently for thread-safe builds of PHP.
+	mu sync.RWMutex
+
+	// Held by the active context for non thread-safe builds of PHP, which share
+	// a single executor, and can therefore only execute one context at a time.
+	exec sync.Mutex
+
+	// Worker processes executing contexts, for engines created by NewIsolated.
+	isolation *isolation
 }
 
 // This contains a reference to the active engine, if any.
 var engine *Engine
 
+// Whether PHP has been built with thread-safety (ZTS) enabled.
+var threadSafe = (C.engine_thread_safe() == 1)
+
+// ThreadSafe returns true if the PHP library linked against has been built with
+// thread-safety (ZTS) enabled. For thread-safe builds, separate contexts can be
+// executed concurrently in separate goroutines, otherwise only a single context
+// can be active at any time.
+func ThreadSafe() bool {
+	return threadSafe
+}
+
+// Config represents configuration for a PHP engine instance.
+type Config struct {
+	// IniFile, if set, is the path to a php.ini file loaded on engine startup.
+	// Directives contained in the file take precedence over the defaults used by
+	// the engine.
+	IniFile string
+
+	// Ini contains php.ini directives set on engine startup, which take
+	// precedence over both the engine defaults and any directives contained in
+	// IniFile. Values are interpreted in the same way as for php.ini files, and
+	// may therefore contain constants and expressions, e.g. 'E_ALL & ~E_NOTICE'.
+	Ini map[string]string
+
+	// Extensions and ZendExtensions contain the shared PHP extensions and Zend
+	// extensions loaded on engine startup, in order, as per the 'extension' and
+	// 'zend_extension' php.ini directives. Extensions may be given as file names
+	// relative to the directory set in the 'extension_dir' directive (which can
+	// be set in Ini), or as absolute paths. Failing to load an extension does not
+	// prevent the engine from starting up; Engine.Extensions can be used for
+	// checking which extensions have been loaded.
+	Extensions     []string
+	ZendExtensions []string
+
+	// Modules contains PHP extensions implemented in Go, registered on engine
+	// startup in addition to any built-in or shared extensions.
+	Modules []*Module
+}
+
+// Extension represents a PHP extension loaded by the engine.
+type Extension struct {
+	Name    string
+	Version string
+
+	// Zend is true for Zend extensions (such as OPcache or Xdebug), and false for
+	// regular PHP extensions.
+	Zend bool
+}
+
+// IniEntries returns the php.ini directives for the configuration, in the format
+// used by php.ini files.
+func (c Config) iniEntries() (string, error) {
+	var buf bytes.Buffer
+
+	if c.IniFile != "" {
+		data, err := ioutil.ReadFile(c.IniFile)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read ini file: %s", err)
+		}
+
+		// Directives following a per-directory or per-host section in the file
+		// would otherwise only apply to that section.
+		buf.Write(data)
+		buf.WriteString("\n[PHP]\n")
+	}
+
+	names := make([]string, 0, len(c.Ini))
+	for name := range c.Ini {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := c.Ini[name]
+		if name == "" || strings.ContainsAny(name, "=[]\r\n") || strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("Invalid ini directive '%s'", name)
+		}
+
+		fmt.Fprintf(&buf, "%s = %s\n", name, value)
+	}
+
+	directives := []struct {
+		name  string
+		files []string
+	}{
+		{"extension", c.Extensions},
+		{"zend_extension", c.ZendExtensions},
+	}
+
+	for _, d := range directives {
+		for _, file := range d.files {
+			if file == "" || strings.ContainsAny(file, "\r\n") {
+				return "", fmt.Errorf("Invalid %s '%s'", d.name, file)
+			}
+
+			fmt.Fprintf(&buf, "%s = \"%s\"\n", d.name, file)
+		}
+	}
+
+	return buf.String(), nil
+}
+
 // New initializes a PHP engine instance on which contexts can be executed. It
 // corresponds to PHP's MINIT (module init) phase.
 func New() (*Engine, error) {
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig initializes a PHP engine instance in the same way as New, using
+// the configuration given. Directives set by the configuration are merged over
+// the defaults used by the engine, and apply to all contexts created for the
+// engine; these can be overridden for individual contexts with Context.SetIni.
+func NewWithConfig(config Config) (*Engine, error) {
 	if engine != nil {
 		return nil, fmt.Errorf("Cannot activate multiple engine instances")
 	}
 
-	ptr, err := C.engine_init()
+	ini, err := config.iniEntries()
 	if err != nil {
-		return nil, fmt.Errorf("PHP engine failed to initialize")
+		return nil, err
 	}
 
-	engine = &Engine{
-		engine:    ptr,
+	e := &Engine{
+		config:    config,
 		contexts:  make(map[*C.struct__engine_context]*Context),
 		receivers: make(map[string]*Receiver),
+		functions: make(map[string]reflect.Value),
+		constants: make(map[string]interface{}),
+		modules:   make(map[string]*Module),
+		mounts:    make(map[string]mountFS),
+	}
+
+	for _, m := range config.Modules {
+		if err := e.defineModule(m); err != nil {
+			C.module_free_all()
+			return nil, err
+		}
 	}
 
-	return engine, nil
+	entries := C.CString(ini)
+	defer C.free(unsafe.Pointer(entries))
+
+	// Modules are started as part of engine initialization, and require access
+	// to the engine for registering their definitions.
+	engine = e
+
+	ptr, err := C.engine_init(entries)
+	if err != nil {
+		engine = nil
+		return nil, fmt.Errorf("PHP engine failed to initialize")
+	}
+
+	e.engine = ptr
+
+	for _, m := range config.Modules {
+		if m.err != nil {
+			e.Destroy()
+			return nil, fmt.Errorf("Failed to start module '%s': %s", m.name, m.err)
+		}
+	}
+
+	return e, nil
 }
 
 // NewContext creates a new execution context for the active engine and returns
 // an error if the execution context failed to initialize at any point. This
 // corresponds to PHP's RINIT (request init) phase.
+//
+// For thread-safe builds of PHP, the calling goroutine is locked to its current
+// OS thread until the context is destroyed, and the context (as well as any
+// values created for it) may only be used from that goroutine. For non
+// thread-safe builds, only a single context may be active at any time, and
+// NewContext blocks until any active context has been destroyed.
 func (e *Engine) NewContext() (*Context, error) {
+	if e.isolation != nil {
+		return e.isolation.newContext(nil, nil)
+	}
+
+	return e.newContext(nil, nil)
+}
+
+// NewContextFromRequest creates a new execution context for the active engine,
+// with request data populated from the HTTP request given. Query parameters,
+// POST data (including file uploads), cookies and headers are made available
+// to PHP scripts in the $_GET, $_POST, $_FILES, $_COOKIE and $_SERVER arrays,
+// respectively.
+//
+// Server variables set from the request can be extended or overridden by the
+// vars given; these are typically used for setting script-specific variables,
+// such as 'SCRIPT_FILENAME', 'SCRIPT_NAME' and 'DOCUMENT_ROOT'.
+func (e *Engine) NewContextFromRequest(r *http.Request, vars map[string]string) (*Context, error) {
+	server := requestVariables(r)
+	for k, v := range vars {
+		server[k] = v
+	}
+
+	if e.isolation != nil {
+		return e.isolation.newContext(r, server)
+	}
+
+	return e.newContext(r, server)
+}
+
+func (e *Engine) newContext(r *http.Request, server map[string]string) (*Context, error) {
+	// Thread-safe builds of PHP keep request state in thread-local storage, and
+	// contexts are therefore bound to the thread they were created on, while
+	// non thread-safe builds only allow for a single active context.
+	if threadSafe {
+		runtime.LockOSThread()
+		e.defineThread()
+	} else {
+		e.exec.Lock()
+	}
+
 	ptr, err := C.context_new()
 	if err != nil {
+		e.unlockContext()
 		return nil, fmt.Errorf("Failed to initialize context for PHP engine")
 	}
 
@@ -68,14 +293,196 @@ func (e *Engine) NewContext() (*Context, error) {
 		Header:  make(http.Header),
 		context: ptr,
 		values:  make([]*Value, 0),
+		request: r,
+		server:  server,
+	}
+
+	if r != nil {
+		setRequestInfo(ptr, r, server)
 	}
 
-	// Store reference to context, using pointer as key.
+	// Store reference to context, using pointer as key. This needs to happen
+	// before the request starts, as request data is read during startup.
+	e.mu.Lock()
 	e.contexts[ptr] = ctx
+	e.mu.Unlock()
+
+	if _, err := C.context_startup(ptr); err != nil {
+		e.mu.Lock()
+		delete(e.contexts, ptr)
+		e.mu.Unlock()
+
+		e.unlockContext()
+		return nil, fmt.Errorf("Failed to initialize context for PHP engine")
+	}
+
+	if err := e.registerMounts(); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	if err := e.defineConstants(ctx); err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
 
 	return ctx, nil
 }
 
+// DefineThread registers method receivers, functions and scalar constants
+// defined for the engine on the current thread, where these have not already
+// been registered. This is required for thread-safe builds of PHP, which keep
+// separate class, function and constant tables for each thread.
+func (e *Engine) defineThread() {
+	var receivers, functions []string
+	constants := make(map[string]interface{})
+
+	e.mu.RLock()
+	for name := range e.receivers {
+		receivers = append(receivers, name)
+	}
+
+	for name := range e.functions {
+		functions = append(functions, name)
+	}
+
+	for name, val := range e.constants {
+		if !isArrayConstant(val) {
+			constants[name] = val
+		}
+	}
+	e.mu.RUnlock()
+
+	for _, name := range receivers {
+		n := C.CString(name)
+		C.receiver_define_thread(n)
+		C.free(unsafe.Pointer(n))
+	}
+
+	for _, name := range functions {
+		n := C.CString(name)
+		C.function_define_thread(n)
+		C.free(unsafe.Pointer(n))
+	}
+
+	for name, val := range constants {
+		defineConstant(name, val)
+	}
+}
+
+// UnlockContext unlocks the calling goroutine from its current OS thread, as
+// previously locked during context creation for thread-safe builds of PHP, or
+// allows for other contexts to be created for non thread-safe builds.
+func (e *Engine) unlockContext() {
+	if threadSafe {
+		runtime.UnlockOSThread()
+	} else {
+		e.exec.Unlock()
+	}
+}
+
+// RequestVariables returns the set of CGI-style server variables derived from
+// the HTTP request given.
+func requestVariables(r *http.Request) map[string]string {
+	vars := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_SOFTWARE":   "go-php",
+		"SERVER_PROTOCOL":   r.Proto,
+		"REQUEST_METHOD":    r.Method,
+		"REQUEST_URI":       r.URL.RequestURI(),
+		"QUERY_STRING":      r.URL.RawQuery,
+		"PHP_SELF":          r.URL.Path,
+		"REQUEST_SCHEME":    "http",
+	}
+
+	if r.RequestURI != "" {
+		vars["REQUEST_URI"] = r.RequestURI
+	}
+
+	if r.TLS != nil {
+		vars["HTTPS"] = "on"
+		vars["REQUEST_SCHEME"] = "https"
+	}
+
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		vars["REMOTE_ADDR"], vars["REMOTE_PORT"] = host, port
+	} else {
+		vars["REMOTE_ADDR"] = r.RemoteAddr
+	}
+
+	if host, port, err := net.SplitHostPort(r.Host); err == nil {
+		vars["SERVER_NAME"], vars["SERVER_PORT"] = host, port
+	} else if r.TLS != nil {
+		vars["SERVER_NAME"], vars["SERVER_PORT"] = r.Host, "443"
+	} else {
+		vars["SERVER_NAME"], vars["SERVER_PORT"] = r.Host, "80"
+	}
+
+	if t := r.Header.Get("Content-Type"); t != "" {
+		vars["CONTENT_TYPE"] = t
+	}
+
+	if r.ContentLength > 0 {
+		vars["CONTENT_LENGTH"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	for k, v := range r.Header {
+		k = strings.ToUpper(strings.Replace(k, "-", "_", -1))
+		if k == "CONTENT_TYPE" || k == "CONTENT_LENGTH" {
+			continue
+		}
+
+		// The 'Proxy' header is skipped, as 'HTTP_PROXY' is commonly used for
+		// configuring outgoing proxies (see https://httpoxy.org).
+		if k == "PROXY" {
+			continue
+		}
+
+		if k == "COOKIE" {
+			vars["HTTP_"+k] = strings.Join(v, "; ")
+		} else {
+			vars["HTTP_"+k] = strings.Join(v, ", ")
+		}
+	}
+
+	if r.Host != "" {
+		vars["HTTP_HOST"] = r.Host
+	}
+
+	return vars
+}
+
+// SetRequestInfo sets request information used by PHP during request startup
+// for the context pointer given.
+func setRequestInfo(ptr *C.struct__engine_context, r *http.Request, server map[string]string) {
+	var length int64
+	if r.ContentLength > 0 {
+		length = r.ContentLength
+	}
+
+	proto := r.ProtoMajor*1000 + r.ProtoMinor*100
+
+	method := C.CString(r.Method)
+	defer C.free(unsafe.Pointer(method))
+
+	uri := C.CString(server["REQUEST_URI"])
+	defer C.free(unsafe.Pointer(uri))
+
+	query := C.CString(r.URL.RawQuery)
+	defer C.free(unsafe.Pointer(query))
+
+	ctype := C.CString(r.Header.Get("Content-Type"))
+	defer C.free(unsafe.Pointer(ctype))
+
+	cookies := C.CString(strings.Join(r.Header["Cookie"], "; "))
+	defer C.free(unsafe.Pointer(cookies))
+
+	path := C.CString(server["SCRIPT_FILENAME"])
+	defer C.free(unsafe.Pointer(path))
+
+	C.context_set_request(ptr, method, uri, query, ctype, C.long(length), cookies, path, C.int(proto))
+}
+
 // Define registers a PHP class for the name passed, using function fn as
 // constructor for individual object instances as needed by the PHP context.
 //
@@ -85,47 +492,341 @@ func (e *Engine) NewContext() (*Context, error) {
 // context, and should return a method receiver instance, or nil on error (in
 // which case, an exception is thrown on the PHP object constructor).
 func (e *Engine) Define(name string, fn func(args []interface{}) interface{}) error {
-	if _, exists := e.receivers[name]; exists {
+	if e.receiver(name) != nil {
 		return fmt.Errorf("Failed to define duplicate receiver '%s'", name)
 	}
 
 	rcvr := &Receiver{
-		name:    name,
-		create:  fn,
-		objects: make(map[*C.struct__engine_receiver]*ReceiverObject),
+		name:      name,
+		create:    fn,
+		objects:   make(map[*C.struct__engine_receiver]*ReceiverObject),
+		instances: make(map[interface{}]*C.struct__engine_receiver),
 	}
 
 	n := C.CString(name)
 	defer C.free(unsafe.Pointer(n))
 
 	C.receiver_define(n)
+
+	e.mu.Lock()
 	e.receivers[name] = rcvr
+	e.mu.Unlock()
 
 	return nil
 }
 
+// DefineFunc registers a global PHP function for the name passed, which calls
+// the Go function fn whenever called from a PHP context. Functions defined are
+// available to all contexts created for the active engine.
+//
+// Arguments passed from the PHP context are converted to the types expected by
+// fn as per Value.Decode, and any results returned are converted to PHP values
+// as per NewValue. Functions returning multiple results return an indexed array
+// to PHP. If the last result returned by fn is a non-nil error, an exception is
+// thrown in the PHP context with the error message, as is the case for any
+// arguments that cannot be converted, such as fractional or overflowing numbers
+// passed for integer arguments. Objects passed as *ObjectValue arguments
+// only hold a handle to the live PHP object for the duration of the call.
+func (e *Engine) DefineFunc(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("Failed to define function '%s' for non-function value of type '%T'", name, fn)
+	}
+
+	// PHP function names are case-insensitive.
+	key := strings.ToLower(name)
+	if _, exists := e.function(key); exists {
+		return fmt.Errorf("Failed to define duplicate function '%s'", name)
+	}
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	// Function registration may raise errors which are reported to the active
+	// context, and which require access to the engine.
+	if _, err := C.function_define(n); err != nil {
+		return fmt.Errorf("Failed to define function '%s'", name)
+	}
+
+	e.mu.Lock()
+	e.functions[key] = v
+	e.mu.Unlock()
+
+	return nil
+}
+
+// DefineConstant registers a PHP constant for the name passed, available to all
+// contexts subsequently created for the active engine. Constant names may be
+// namespaced (e.g. 'App\Config\DEBUG'), and are case-sensitive. Values are
+// converted as per NewValue, and are limited to scalar and array values. An
+// error is returned for any other type of value.
+//
+// Scalar constants are registered once for the engine, rather than for each
+// context, while array constants are defined in each context as it is created,
+// as persistent arrays are not supported by all versions of PHP. In either case,
+// constants cannot be redefined by scripts executed in these contexts.
+func (e *Engine) DefineConstant(name string, val interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, exists := e.constants[name]; exists {
+		return fmt.Errorf("Failed to define duplicate constant '%s'", name)
+	}
+
+	if err := checkConstant(name, val); err != nil {
+		return err
+	}
+
+	if isArrayConstant(val) {
+		if constantExists(name) {
+			return fmt.Errorf("Failed to define constant '%s'", name)
+		}
+	} else if err := defineConstant(name, val); err != nil {
+		return err
+	}
+
+	e.constants[name] = val
+
+	return nil
+}
+
+// DefineConstants defines array constants registered for the engine in the
+// context given, which is expected to be the active context.
+func (e *Engine) defineConstants(ctx *Context) error {
+	constants := make(map[string]interface{})
+
+	e.mu.RLock()
+	for name, val := range e.constants {
+		if isArrayConstant(val) {
+			constants[name] = val
+		}
+	}
+	e.mu.RUnlock()
+
+	for name, val := range constants {
+		if err := ctx.DefineConstant(name, val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DefineConstant registers a persistent PHP constant for the name and scalar
+// value given, on the current thread.
+func defineConstant(name string, val interface{}) error {
+	v, err := NewValue(val)
+	if err != nil {
+		return err
+	}
+
+	defer v.Destroy()
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	if _, err := C.module_constant_define(n, v.value); err != nil {
+		return fmt.Errorf("Failed to define constant '%s'", name)
+	}
+
+	return nil
+}
+
+// ConstantExists returns whether a constant is registered for the name given,
+// on the current thread.
+func constantExists(name string) bool {
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	return bool(C.module_constant_exists(n))
+}
+
+// IsArrayConstant returns whether the constant value given is converted to a
+// PHP array, as per NewValue.
+func isArrayConstant(val interface{}) bool {
+	switch constantKind(val) {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	}
+
+	return false
+}
+
+// Extensions returns the PHP extensions and Zend extensions loaded by the engine,
+// including any built-in extensions, along with their versions.
+func (e *Engine) Extensions() []Extension {
+	if e.engine == nil {
+		return nil
+	}
+
+	list := &Value{value: C.engine_extensions()}
+	defer list.Destroy()
+
+	var extensions []Extension
+
+	for _, entry := range list.Slice() {
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) != 3 {
+			continue
+		}
+
+		name, _ := fields[0].(string)
+		version, _ := fields[1].(string)
+		zend, _ := fields[2].(bool)
+
+		extensions = append(extensions, Extension{Name: name, Version: version, Zend: zend})
+	}
+
+	return extensions
+}
+
 // Destroy shuts down and frees any resources related to the PHP engine bindings.
-func (e *Engine) Destroy() {
+//
+// Any contexts not yet destroyed are destroyed along with the engine. For
+// thread-safe builds of PHP, contexts created on other threads can only be
+// destroyed from the goroutines that created them, and an error is returned,
+// with the engine left intact, while any such contexts remain.
+func (e *Engine) Destroy() error {
 	if e.engine == nil {
-		return
+		return nil
 	}
 
-	for _, r := range e.receivers {
-		r.Destroy()
+	e.mu.RLock()
+	remote := 0
+	for ptr := range e.contexts {
+		if C.context_is_local(ptr) == 0 {
+			remote++
+		}
+	}
+	e.mu.RUnlock()
+
+	if remote > 0 {
+		return fmt.Errorf("Failed to destroy engine with %d contexts active on other threads", remote)
+	}
+
+	if e.isolation != nil {
+		e.isolation.stop()
 	}
 
+	e.mu.Lock()
+	receivers := e.receivers
+
 	e.receivers = nil
+	e.functions = nil
+	e.constants = nil
+	e.modules = nil
+	e.mounts = nil
+	e.mu.Unlock()
+
+	for _, r := range receivers {
+		r.Destroy()
+	}
 
+	e.mu.RLock()
+	contexts := make([]*Context, 0, len(e.contexts))
 	for _, c := range e.contexts {
+		contexts = append(contexts, c)
+	}
+	e.mu.RUnlock()
+
+	for _, c := range contexts {
 		c.Destroy()
 	}
 
+	e.mu.Lock()
 	e.contexts = nil
+	e.mu.Unlock()
 
 	C.engine_shutdown(e.engine)
 	e.engine = nil
 
 	engine = nil
+
+	return nil
+}
+
+// Context returns the context for the pointer given, if any.
+func (e *Engine) context(ptr *C.struct__engine_context) *Context {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.contexts[ptr]
+}
+
+// Receiver returns the method receiver defined for the name given, if any.
+func (e *Engine) receiver(name string) *Receiver {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.receivers[name]
+}
+
+// ReceiverObject returns the method receiver object attached to the PHP object
+// given, if any.
+func (e *Engine) receiverObject(rcvr *C.struct__engine_receiver) *ReceiverObject {
+	r := e.receiver(C.GoString(C._receiver_get_name(rcvr)))
+	if r == nil {
+		return nil
+	}
+
+	return r.object(rcvr)
+}
+
+// ReceiverInstance returns the PHP object attached to the method receiver
+// instance given, if the object was created in the context given.
+func (e *Engine) receiverInstance(instance interface{}, c *Context) *C.struct__engine_receiver {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	receivers := make([]*Receiver, 0, len(e.receivers))
+	for _, r := range e.receivers {
+		receivers = append(receivers, r)
+	}
+	e.mu.RUnlock()
+
+	for _, r := range receivers {
+		if rcvr := r.instance(instance, c); rcvr != nil {
+			return rcvr
+		}
+	}
+
+	return nil
+}
+
+// Module returns the module defined for the name given, if any.
+func (e *Engine) module(name string) *Module {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.modules[name]
+}
+
+// Function returns the Go function defined for the case-insensitive name given,
+// if any.
+func (e *Engine) function(name string) (reflect.Value, bool) {
+	if e == nil {
+		return reflect.Value{}, false
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	fn, exists := e.functions[strings.ToLower(name)]
+	return fn, exists
 }
 
 func write(w io.Writer, buffer unsafe.Pointer, length C.uint) C.int {
@@ -144,25 +845,65 @@ func write(w io.Writer, buffer unsafe.Pointer, length C.uint) C.int {
 
 //export engineWriteOut
 func engineWriteOut(ctx *C.struct__engine_context, buffer unsafe.Pointer, length C.uint) C.int {
-	if engine == nil || engine.contexts[ctx] == nil {
+	c := engine.context(ctx)
+	if c == nil {
 		return -1
 	}
 
-	return write(engine.contexts[ctx].Output, buffer, length)
+	return write(c.Output, buffer, length)
 }
 
 //export engineWriteLog
 func engineWriteLog(ctx *C.struct__engine_context, buffer unsafe.Pointer, length C.uint) C.int {
-	if engine == nil || engine.contexts[ctx] == nil {
+	c := engine.context(ctx)
+	if c == nil {
 		return -1
 	}
 
-	return write(engine.contexts[ctx].Log, buffer, length)
+	return write(c.Log, buffer, length)
+}
+
+//export engineReadPost
+func engineReadPost(ctx *C.struct__engine_context, buffer unsafe.Pointer, length C.uint) C.int {
+	c := engine.context(ctx)
+	if c == nil {
+		return 0
+	}
+
+	r := c.request
+	if r == nil || r.Body == nil || length == 0 {
+		return 0
+	}
+
+	// PHP considers short reads as the end of request data, so we attempt to fill
+	// the buffer entirely.
+	buf := (*[1 << 30]byte)(buffer)[:length:length]
+	n, _ := io.ReadFull(r.Body, buf)
+
+	return C.int(n)
+}
+
+//export engineRegisterVariables
+func engineRegisterVariables(ctx *C.struct__engine_context, vars unsafe.Pointer) {
+	c := engine.context(ctx)
+	if c == nil {
+		return
+	}
+
+	for k, v := range c.server {
+		key, value := C.CString(k), C.CString(v)
+
+		C.engine_register_variable(key, value, vars)
+
+		C.free(unsafe.Pointer(key))
+		C.free(unsafe.Pointer(value))
+	}
 }
 
 //export engineSetHeader
 func engineSetHeader(ctx *C.struct__engine_context, operation C.uint, buffer unsafe.Pointer, length C.uint) {
-	if engine == nil || engine.contexts[ctx] == nil {
+	c := engine.context(ctx)
+	if c == nil {
 		return
 	}
 
@@ -176,23 +917,76 @@ func engineSetHeader(ctx *C.struct__engine_context, operation C.uint, buffer uns
 	switch operation {
 	case 0: // Replace header.
 		if len(split) == 2 && split[1] != "" {
-			engine.contexts[ctx].Header.Set(split[0], split[1])
+			c.Header.Set(split[0], split[1])
 		}
 	case 1: // Append header.
 		if len(split) == 2 && split[1] != "" {
-			engine.contexts[ctx].Header.Add(split[0], split[1])
+			c.Header.Add(split[0], split[1])
 		}
 	case 2: // Delete header.
 		if split[0] != "" {
-			engine.contexts[ctx].Header.Del(split[0])
+			c.Header.Del(split[0])
 		}
 	}
 }
 
+//export engineError
+func engineError(ctx *C.struct__engine_context, level C.int, message *C.char, file *C.char, line C.uint) C.int {
+	c := engine.context(ctx)
+	if c == nil {
+		return 0
+	}
+
+	err := &Error{
+		Level:   ErrorLevel(level),
+		Message: C.GoString(message),
+		File:    C.GoString(file),
+		Line:    int(line),
+	}
+
+	// Fatal errors are returned by the executing method.
+	if err.Level&levelFatal != 0 {
+		c.err = err
+	}
+
+	if c.ErrorHandler != nil && c.ErrorHandler(err) {
+		return 1
+	}
+
+	return 0
+}
+
+//export engineSetException
+func engineSetException(ctx *C.struct__engine_context, class *C.char, message *C.char, code C.long, file *C.char, line C.uint, trace *C.char) {
+	c := engine.context(ctx)
+	if c == nil {
+		return
+	}
+
+	c.err = &Exception{
+		Class:   C.GoString(class),
+		Message: C.GoString(message),
+		Code:    int64(code),
+		File:    C.GoString(file),
+		Line:    int(line),
+		Trace:   C.GoString(trace),
+	}
+}
+
+//export engineSetStatus
+func engineSetStatus(ctx *C.struct__engine_context, status C.int) {
+	c := engine.context(ctx)
+	if c == nil {
+		return
+	}
+
+	c.status = int(status)
+}
+
 //export engineReceiverNew
 func engineReceiverNew(rcvr *C.struct__engine_receiver, args unsafe.Pointer) C.int {
-	n := C.GoString(C._receiver_get_name(rcvr))
-	if engine == nil || engine.receivers[n] == nil {
+	r := engine.receiver(C.GoString(C._receiver_get_name(rcvr)))
+	if r == nil {
 		return 1
 	}
 
@@ -203,24 +997,38 @@ func engineReceiverNew(rcvr *C.struct__engine_receiver, args unsafe.Pointer) C.i
 
 	defer va.Destroy()
 
-	obj, err := engine.receivers[n].NewObject(va.Slice())
+	// Handles for objects passed as arguments are not retained past the call.
+	list := va.Slice()
+	defer ReleaseObjects(list)
+
+	obj, err := r.NewObject(list)
 	if err != nil {
 		return 1
 	}
 
-	engine.receivers[n].objects[rcvr] = obj
+	r.setObject(rcvr, obj, currentContext())
 
 	return 0
 }
 
+//export engineReceiverFree
+func engineReceiverFree(rcvr *C.struct__engine_receiver) {
+	r := engine.receiver(C.GoString(C._receiver_get_name(rcvr)))
+	if r == nil {
+		return
+	}
+
+	r.deleteObject(rcvr)
+}
+
 //export engineReceiverGet
 func engineReceiverGet(rcvr *C.struct__engine_receiver, name *C.char) unsafe.Pointer {
-	n := C.GoString(C._receiver_get_name(rcvr))
-	if engine == nil || engine.receivers[n].objects[rcvr] == nil {
+	obj := engine.receiverObject(rcvr)
+	if obj == nil {
 		return nil
 	}
 
-	val, err := engine.receivers[n].objects[rcvr].Get(C.GoString(name))
+	val, err := obj.Get(C.GoString(name))
 	if err != nil {
 		return nil
 	}
@@ -230,8 +1038,8 @@ func engineReceiverGet(rcvr *C.struct__engine_receiver, name *C.char) unsafe.Poi
 
 //export engineReceiverSet
 func engineReceiverSet(rcvr *C.struct__engine_receiver, name *C.char, val unsafe.Pointer) {
-	n := C.GoString(C._receiver_get_name(rcvr))
-	if engine == nil || engine.receivers[n].objects[rcvr] == nil {
+	obj := engine.receiverObject(rcvr)
+	if obj == nil {
 		return
 	}
 
@@ -240,17 +1048,23 @@ func engineReceiverSet(rcvr *C.struct__engine_receiver, name *C.char, val unsafe
 		return
 	}
 
-	engine.receivers[n].objects[rcvr].Set(C.GoString(name), v.Interface())
+	defer v.Destroy()
+
+	// Handles for objects set as property values are not retained.
+	pv := v.Interface()
+	defer ReleaseObjects(pv)
+
+	obj.Set(C.GoString(name), pv)
 }
 
 //export engineReceiverExists
 func engineReceiverExists(rcvr *C.struct__engine_receiver, name *C.char) C.int {
-	n := C.GoString(C._receiver_get_name(rcvr))
-	if engine == nil || engine.receivers[n].objects[rcvr] == nil {
+	obj := engine.receiverObject(rcvr)
+	if obj == nil {
 		return 0
 	}
 
-	if engine.receivers[n].objects[rcvr].Exists(C.GoString(name)) {
+	if obj.Exists(C.GoString(name)) {
 		return 1
 	}
 
@@ -259,8 +1073,8 @@ func engineReceiverExists(rcvr *C.struct__engine_receiver, name *C.char) C.int {
 
 //export engineReceiverCall
 func engineReceiverCall(rcvr *C.struct__engine_receiver, name *C.char, args unsafe.Pointer) unsafe.Pointer {
-	n := C.GoString(C._receiver_get_name(rcvr))
-	if engine == nil || engine.receivers[n].objects[rcvr] == nil {
+	obj := engine.receiverObject(rcvr)
+	if obj == nil {
 		return nil
 	}
 
@@ -272,10 +1086,141 @@ func engineReceiverCall(rcvr *C.struct__engine_receiver, name *C.char, args unsa
 
 	defer va.Destroy()
 
-	val := engine.receivers[n].objects[rcvr].Call(C.GoString(name), va.Slice())
+	// Handles for objects passed as arguments are not retained past the call.
+	list := va.Slice()
+	defer ReleaseObjects(list)
+
+	val := obj.Call(C.GoString(name), list)
 	if val == nil {
 		return nil
 	}
 
 	return val.Ptr()
 }
+
+//export engineFunctionCall
+func engineFunctionCall(name *C.char, args unsafe.Pointer) unsafe.Pointer {
+	fn, exists := engine.function(C.GoString(name))
+	if !exists {
+		return nil
+	}
+
+	// Process input arguments.
+	va, err := NewValueFromPtr(args)
+	if err != nil {
+		return nil
+	}
+
+	defer va.Destroy()
+
+	// Handles for objects passed as arguments are not retained past the call.
+	list := va.Slice()
+	defer ReleaseObjects(list)
+
+	val, err := callFunc(fn, list)
+	if err != nil {
+		msg := C.CString(err.Error())
+		defer C.free(unsafe.Pointer(msg))
+
+		C.function_throw(msg)
+		return nil
+	} else if val == nil {
+		return nil
+	}
+
+	return val.Ptr()
+}
+
+//export engineModuleStartup
+func engineModuleStartup(name *C.char, number C.int) {
+	m := engine.module(C.GoString(name))
+	if m == nil {
+		return
+	}
+
+	m.err = m.startup(engine, number)
+}
+
+//export engineModuleRequestStartup
+func engineModuleRequestStartup(name *C.char) {
+	m := engine.module(C.GoString(name))
+	if m == nil || m.RequestStartup == nil {
+		return
+	}
+
+	if c := currentContext(); c != nil {
+		m.RequestStartup(c)
+	}
+}
+
+//export engineModuleRequestShutdown
+func engineModuleRequestShutdown(name *C.char) {
+	m := engine.module(C.GoString(name))
+	if m == nil || m.RequestShutdown == nil {
+		return
+	}
+
+	if c := currentContext(); c != nil {
+		m.RequestShutdown(c)
+	}
+}
+
+//export engineMountOpen
+func engineMountOpen(scheme *C.char, name *C.char) C.uint {
+	return C.uint(mountOpen(engine.mountFS(C.GoString(scheme)), C.GoString(name)))
+}
+
+//export engineMountRead
+func engineMountRead(handle C.uint, buffer unsafe.Pointer, length C.int) C.int {
+	buf := (*[1 << 30]byte)(buffer)[:length:length]
+	return C.int(mountRead(uint(handle), buf))
+}
+
+//export engineMountSeek
+func engineMountSeek(handle C.uint, offset C.long, whence C.int) C.long {
+	return C.long(mountSeek(uint(handle), int64(offset), int(whence)))
+}
+
+//export engineMountStat
+func engineMountStat(scheme *C.char, name *C.char, size *C.long, mtime *C.long) C.int {
+	fsys := engine.mountFS(C.GoString(scheme))
+	if fsys == nil {
+		return 0
+	}
+
+	info, err := fsys.stat(mountPath(C.GoString(name)))
+	if err != nil {
+		return 0
+	}
+
+	*size, *mtime = C.long(info.Size()), C.long(info.ModTime().Unix())
+
+	return C.int(mountStatKind(info))
+}
+
+//export engineMountFileStat
+func engineMountFileStat(handle C.uint, size *C.long, mtime *C.long) C.int {
+	info, err := mountFileStat(uint(handle))
+	if err != nil {
+		return 0
+	}
+
+	*size, *mtime = C.long(info.Size()), C.long(info.ModTime().Unix())
+
+	return C.int(mountStatKind(info))
+}
+
+//export engineMountClose
+func engineMountClose(handle C.uint) {
+	mountClose(uint(handle))
+}
+
+//export engineMountResolve
+func engineMountResolve(name *C.char, executing *C.char) *C.char {
+	url := engine.mountResolve(C.GoString(name), C.GoString(executing))
+	if url == "" {
+		return nil
+	}
+
+	return C.CString(url)
+}
@@ -7,6 +7,7 @@ package php
 import (
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -37,6 +38,25 @@ func (s *Script) Remove() {
 
 var e *Engine
 
+func TestMain(m *testing.M) {
+	// Worker processes for isolated engines and process-based pools are started
+	// from the test binary, and require the definitions used by tests.
+	ServeWorker(func(config Config) (*Engine, error) {
+		w, err := NewWithConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		// Terminate the worker process executing the calling context or job.
+		w.DefineFunc("test_crash", func() { os.Exit(3) })
+		w.DefineFunc("pool_crash", func() { os.Exit(1) })
+
+		return w, nil
+	})
+
+	os.Exit(m.Run())
+}
+
 func TestEngineNew(t *testing.T) {
 	var err error
 
@@ -78,13 +98,178 @@ func TestEngineDefine(t *testing.T) {
 	}
 }
 
+func TestEngineDefineFunc(t *testing.T) {
+	if e.functions == nil {
+		t.Fatalf("New(): `Engine.functions` is `nil` but no error returned")
+	}
+
+	fn := func(s string) string {
+		return s
+	}
+
+	if err := e.DefineFunc("test_define_func", fn); err != nil {
+		t.Errorf("Engine.DefineFunc(): %s", err)
+	}
+
+	if len(e.functions) != 1 {
+		t.Errorf("Engine.DefineFunc(): `Engine.functions` length is %d, should be 1", len(e.functions))
+	}
+
+	if err := e.DefineFunc("Test_Define_Func", fn); err == nil {
+		t.Errorf("Engine.DefineFunc(): Incorrectly defined duplicate function")
+	}
+
+	if err := e.DefineFunc("test_define_invalid", "invalid"); err == nil {
+		t.Errorf("Engine.DefineFunc(): Incorrectly defined function for non-function value")
+	}
+}
+
+func TestEngineDefineConstant(t *testing.T) {
+	if err := e.DefineConstant("TEST_ENGINE_CONSTANT", "Hello"); err != nil {
+		t.Errorf("Engine.DefineConstant(): %s", err)
+	}
+
+	if err := e.DefineConstant("TEST_ENGINE_CONSTANT", "World"); err == nil {
+		t.Errorf("Engine.DefineConstant(): Incorrectly defined duplicate constant")
+	}
+
+	if err := e.DefineConstant("TEST_ENGINE_INVALID", struct{}{}); err == nil {
+		t.Errorf("Engine.DefineConstant(): Incorrectly defined constant of invalid type")
+	}
+
+	if err := e.DefineConstant("TEST_ENGINE_ARRAY", []string{"Hello"}); err != nil {
+		t.Errorf("Engine.DefineConstant(): %s", err)
+	}
+
+	if err := e.DefineConstant("E_ALL", 0); err == nil {
+		t.Errorf("Engine.DefineConstant(): Incorrectly defined existing PHP constant")
+	}
+
+	if err := e.DefineConstant("E_STRICT", []int{0}); err == nil {
+		t.Errorf("Engine.DefineConstant(): Incorrectly defined existing PHP constant of array type")
+	}
+}
+
+func TestEngineExtensions(t *testing.T) {
+	loaded := make(map[string]Extension)
+	for _, ext := range e.Extensions() {
+		loaded[ext.Name] = ext
+	}
+
+	for _, name := range []string{"Core", "standard"} {
+		ext, ok := loaded[name]
+		if !ok {
+			t.Errorf("Engine.Extensions(): Expected extension '%s' to be loaded", name)
+		} else if ext.Version == "" || ext.Zend {
+			t.Errorf("Engine.Extensions(): Unexpected extension '%#v'", ext)
+		}
+	}
+}
+
 func TestEngineDestroy(t *testing.T) {
-	e.Destroy()
+	// Contexts created on other threads cannot be destroyed along with the
+	// engine, and need to be destroyed from the goroutines that created them.
+	if ThreadSafe() {
+		created, release, released := make(chan struct{}), make(chan struct{}), make(chan struct{})
+		go func() {
+			c, _ := e.NewContext()
+			close(created)
+
+			<-release
+			c.Destroy()
+			close(released)
+		}()
+
+		<-created
+
+		if err := e.Destroy(); err == nil {
+			t.Errorf("Engine.Destroy(): Incorrectly destroyed engine with context active on other thread")
+		} else if e.engine == nil {
+			t.Errorf("Engine.Destroy(): Destroyed engine despite returning error")
+		}
+
+		close(release)
+		<-released
+	}
+
+	// Contexts not yet destroyed, such as the context created by
+	// TestEngineNewContext, are destroyed along with the engine.
+	var contexts []*Context
+	for _, c := range e.contexts {
+		contexts = append(contexts, c)
+	}
+
+	if err := e.Destroy(); err != nil {
+		t.Fatalf("Engine.Destroy(): %s", err)
+	}
 
 	if e.engine != nil || e.contexts != nil || e.receivers != nil {
 		t.Errorf("Engine.Destroy(): Did not set internal fields to `nil`")
 	}
 
+	for _, c := range contexts {
+		if c.context != nil || c.values != nil {
+			t.Errorf("Engine.Destroy(): Did not destroy remaining context")
+		}
+	}
+
+	if e.Extensions() != nil {
+		t.Errorf("Engine.Extensions(): Returned extensions for destroyed engine")
+	}
+
 	// Attempting to destroy an engine instance twice should be a no-op.
-	e.Destroy()
+	if err := e.Destroy(); err != nil {
+		t.Errorf("Engine.Destroy(): %s", err)
+	}
+}
+
+func TestEngineDestroyFunctions(t *testing.T) {
+	if e.functions != nil || e.constants != nil {
+		t.Errorf("Engine.Destroy(): Did not set function and constant definitions to `nil`")
+	}
+}
+
+func TestEngineNewWithConfig(t *testing.T) {
+	ini, err := NewScript("php.ini", "[PATH=/nonexistent]\nprecision = 5\n[PHP]\ndate.timezone = Europe/Athens\nprecision = 10\n")
+	if err != nil {
+		t.Fatalf("Could not create temporary file for testing: %s", err)
+	}
+
+	defer ini.Remove()
+
+	config := Config{
+		IniFile: ini.Name(),
+		Ini:     map[string]string{"precision": "12", "error_reporting": "E_ALL & ~E_NOTICE"},
+	}
+
+	e, err := NewWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewWithConfig(): %s", err)
+	}
+
+	defer e.Destroy()
+
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	val, _ := c.Eval("return [ini_get('date.timezone'), ini_get('precision'), error_reporting() == (E_ALL & ~E_NOTICE), ini_get('max_execution_time')];")
+	expected := []interface{}{"Europe/Athens", "12", true, "0"}
+
+	if actual := val.Interface(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("NewWithConfig(): Expected ini values '%#v', actual '%#v'", expected, actual)
+	}
+}
+
+func TestEngineNewWithConfigInvalid(t *testing.T) {
+	if _, err := NewWithConfig(Config{IniFile: "/nonexistent/php.ini"}); err == nil {
+		t.Errorf("NewWithConfig(): Incorrectly created engine for non-existent ini file")
+	}
+
+	if _, err := NewWithConfig(Config{Ini: map[string]string{"precision\nmemory_limit": "1"}}); err == nil {
+		t.Errorf("NewWithConfig(): Incorrectly created engine for invalid ini directive")
+	}
+
+	if _, err := NewWithConfig(Config{Extensions: []string{"redis.so\nmemory_limit = 1"}}); err == nil {
+		t.Errorf("NewWithConfig(): Incorrectly created engine for invalid extension")
+	}
 }
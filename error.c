@@ -0,0 +1,118 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#include <stdarg.h>
+
+#include <main/php.h>
+#include <zend_exceptions.h>
+#include <zend_interfaces.h>
+#include <zend_closures.h>
+
+#include "context.h"
+#include "error.h"
+#include "_cgo_export.h"
+
+// The error callback set by PHP, to which errors are passed after processing.
+static void (*error_cb_orig)(int type, const char *filename, const uint lineno, const char *format, va_list args);
+
+// The internal function used as default handler for uncaught exceptions.
+static zend_internal_function error_handler;
+
+// Call original error callback with display and logging of errors disabled.
+static void error_cb_silent(int type, const char *filename, const uint lineno, const char *format, va_list args) {
+	int display = PG(display_errors), log = PG(log_errors);
+
+	PG(display_errors) = 0;
+	PG(log_errors) = 0;
+
+	// Fatal errors do not return, so settings need to be restored before bailing
+	// out of execution.
+	zend_try {
+		error_cb_orig(type, filename, lineno, format, args);
+	} zend_catch {
+		PG(display_errors) = display;
+		PG(log_errors) = log;
+		zend_bailout();
+	} zend_end_try();
+
+	PG(display_errors) = display;
+	PG(log_errors) = log;
+}
+
+// Process error raised by PHP, passing it to the active context before handing
+// off to the original error callback. Errors handled by the context are not
+// displayed or logged.
+static void error_cb(int type, const char *filename, const uint lineno, const char *format, va_list args) {
+	engine_context *context = SG(server_context);
+	int handled = 0;
+
+	if (context != NULL) {
+		char *message = NULL;
+		va_list tmp;
+
+		va_copy(tmp, args);
+		vspprintf(&message, 0, format, tmp);
+		va_end(tmp);
+
+		handled = engineError(context, type, message, (char *) filename, lineno);
+		efree(message);
+	}
+
+	if (handled) {
+		error_cb_silent(type, filename, lineno, format, args);
+	} else {
+		error_cb_orig(type, filename, lineno, format, args);
+	}
+}
+
+// Handle uncaught exception passed as the only argument, passing it to the
+// active context.
+static void error_handle_exception(INTERNAL_FUNCTION_PARAMETERS) {
+	zval *ex;
+
+	if (zend_parse_parameters(ZEND_NUM_ARGS(), "z", &ex) == FAILURE) {
+		return;
+	}
+
+	error_exception(ex);
+}
+
+// Install error callback and prepare default exception handler. This should be
+// called once, after the PHP engine has been initialized.
+void error_init(void) {
+	error_cb_orig = zend_error_cb;
+	zend_error_cb = error_cb;
+
+	_error_handler_init(&error_handler, error_handle_exception);
+}
+
+// Set default exception handler for the current request.
+void error_activate(void) {
+	_error_handler_set(&error_handler);
+}
+
+// Pass exception details to the active context.
+void error_exception(zval *ex) {
+	engine_context *context = SG(server_context);
+
+	if (context != NULL && Z_TYPE_P(ex) == IS_OBJECT) {
+		_error_exception(context, ex);
+	}
+}
+
+// Pass any pending exception to the active context and clear it, allowing for
+// further execution.
+void error_exception_clear(void) {
+	if (EG(exception)) {
+		_error_exception_clear();
+	}
+}
+
+// Restore original error callback.
+void error_shutdown(void) {
+	zend_error_cb = error_cb_orig;
+	_error_handler_destroy(&error_handler);
+}
+
+#include "_error.c"
@@ -0,0 +1,114 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrorLevel represents the level of an error raised by PHP, and corresponds to
+// one of PHP's 'E_*' error constants.
+type ErrorLevel int
+
+// Error levels raised by PHP.
+const (
+	LevelError ErrorLevel = 1 << iota
+	LevelWarning
+	LevelParse
+	LevelNotice
+	LevelCoreError
+	LevelCoreWarning
+	LevelCompileError
+	LevelCompileWarning
+	LevelUserError
+	LevelUserWarning
+	LevelUserNotice
+	LevelStrict
+	LevelRecoverableError
+	LevelDeprecated
+	LevelUserDeprecated
+)
+
+// Error levels which cause script execution to halt.
+const levelFatal = LevelError | LevelParse | LevelCoreError | LevelCompileError | LevelUserError | LevelRecoverableError
+
+// String returns the description used by PHP for the error level.
+func (l ErrorLevel) String() string {
+	switch l {
+	case LevelError, LevelCoreError, LevelCompileError, LevelUserError:
+		return "Fatal error"
+	case LevelRecoverableError:
+		return "Recoverable fatal error"
+	case LevelWarning, LevelCoreWarning, LevelCompileWarning, LevelUserWarning:
+		return "Warning"
+	case LevelParse:
+		return "Parse error"
+	case LevelNotice, LevelUserNotice:
+		return "Notice"
+	case LevelStrict:
+		return "Strict Standards"
+	case LevelDeprecated, LevelUserDeprecated:
+		return "Deprecated"
+	}
+
+	return "Unknown error"
+}
+
+// Error represents an error raised by PHP during script execution, such as a
+// parse error or a fatal runtime error.
+type Error struct {
+	Level   ErrorLevel
+	Message string
+	File    string
+	Line    int
+}
+
+// Error returns the error formatted in the same way as PHP does.
+func (e *Error) Error() string {
+	return fmt.Sprintf("PHP %s: %s in %s on line %d", e.Level, e.Message, e.File, e.Line)
+}
+
+// Exception represents an uncaught exception thrown during script execution.
+// For PHP 7, this applies to any uncaught Throwable, with the exception of
+// parse errors, which are returned as Error values instead.
+type Exception struct {
+	Class   string
+	Message string
+	Code    int64
+	File    string
+	Line    int
+	Trace   string
+}
+
+// Error returns the exception formatted in the same way as PHP does.
+func (e *Exception) Error() string {
+	return fmt.Sprintf("PHP Fatal error: Uncaught %s: %s in %s:%d", e.Class, e.Message, e.File, e.Line)
+}
+
+// InterruptError is returned when script execution is interrupted before it
+// completes, as is the case for Context.ExecContext and Context.EvalContext
+// when the Go context passed is cancelled or its deadline passes.
+type InterruptError struct {
+	// Err is the error returned by the Go context, either context.Canceled or
+	// context.DeadlineExceeded.
+	Err error
+}
+
+// Error returns the reason for which execution was interrupted.
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("PHP execution interrupted: %s", e.Err)
+}
+
+// Unwrap returns the error returned by the Go context.
+func (e *InterruptError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if execution was interrupted because the deadline for
+// the Go context passed.
+func (e *InterruptError) Timeout() bool {
+	return e.Err == context.DeadlineExceeded
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+var errorTests = []struct {
+	script   string
+	expected error
+}{
+	{
+		"$a = ;",
+		&Error{Level: LevelParse, Line: 1},
+	},
+	{
+		"trigger_error('Test Error', E_USER_ERROR);",
+		&Error{Level: LevelUserError, Message: "Test Error", Line: 1},
+	},
+	{
+		"throw new RuntimeException('Test Exception', 42);",
+		&Exception{Class: "RuntimeException", Message: "Test Exception", Code: 42, Line: 1},
+	},
+	{
+		"function a() { throw new Exception('Nested'); }; a();",
+		&Exception{Class: "Exception", Message: "Nested", Line: 1, Trace: "#0 "},
+	},
+}
+
+func TestErrorEval(t *testing.T) {
+	for _, tt := range errorTests {
+		c, _ := e.NewContext()
+
+		_, err := c.Eval(tt.script)
+		c.Destroy()
+
+		if err == nil {
+			t.Errorf("Context.Eval('%s'): Expected error, got none", tt.script)
+			continue
+		}
+
+		switch expected := tt.expected.(type) {
+		case *Error:
+			var actual *Error
+			if !errors.As(err, &actual) {
+				t.Errorf("Context.Eval('%s'): Expected *Error, actual '%#v'", tt.script, err)
+				continue
+			}
+
+			if actual.Level != expected.Level || actual.Line != expected.Line {
+				t.Errorf("Context.Eval('%s'): Expected '%#v', actual '%#v'", tt.script, expected, actual)
+			}
+
+			if !strings.Contains(actual.Message, expected.Message) {
+				t.Errorf("Context.Eval('%s'): Expected message '%s', actual '%s'", tt.script, expected.Message, actual.Message)
+			}
+		case *Exception:
+			var actual *Exception
+			if !errors.As(err, &actual) {
+				t.Errorf("Context.Eval('%s'): Expected *Exception, actual '%#v'", tt.script, err)
+				continue
+			}
+
+			if actual.Class != expected.Class || actual.Message != expected.Message || actual.Code != expected.Code || actual.Line != expected.Line {
+				t.Errorf("Context.Eval('%s'): Expected '%#v', actual '%#v'", tt.script, expected, actual)
+			}
+
+			if !strings.Contains(actual.Trace, expected.Trace) {
+				t.Errorf("Context.Eval('%s'): Expected trace '%s', actual '%s'", tt.script, expected.Trace, actual.Trace)
+			}
+		}
+	}
+}
+
+func TestErrorExec(t *testing.T) {
+	for _, tt := range errorTests {
+		script, err := NewScript("error.php", "<?php "+tt.script)
+		if err != nil {
+			t.Fatalf("Could not create temporary file for testing: %s", err)
+		}
+
+		c, _ := e.NewContext()
+
+		err = c.Exec(script.Name())
+		c.Destroy()
+		script.Remove()
+
+		if err == nil {
+			t.Errorf("Context.Exec('%s'): Expected error, got none", tt.script)
+			continue
+		}
+
+		switch expected := tt.expected.(type) {
+		case *Error:
+			if actual, ok := err.(*Error); !ok || actual.Level != expected.Level || actual.File != script.Name() {
+				t.Errorf("Context.Exec('%s'): Expected '%#v', actual '%#v'", tt.script, expected, err)
+			}
+		case *Exception:
+			if actual, ok := err.(*Exception); !ok || actual.Class != expected.Class || actual.File != script.Name() {
+				t.Errorf("Context.Exec('%s'): Expected '%#v', actual '%#v'", tt.script, expected, err)
+			}
+		}
+	}
+}
+
+var errorHandlerTests = []struct {
+	script  string
+	handled bool
+	level   ErrorLevel
+	message string
+	output  string
+}{
+	{
+		"echo $a;",
+		true,
+		LevelNotice,
+		"Undefined variable: a",
+		"",
+	},
+	{
+		"trigger_error('Test Warning', E_USER_WARNING); echo 'Done';",
+		true,
+		LevelUserWarning,
+		"Test Warning",
+		"Done",
+	},
+	{
+		"trigger_error('Test Deprecated', E_USER_DEPRECATED);",
+		false,
+		LevelUserDeprecated,
+		"Test Deprecated",
+		"Deprecated: Test Deprecated",
+	},
+}
+
+func TestErrorHandler(t *testing.T) {
+	var w bytes.Buffer
+	var actual []*Error
+
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	c.Output = &w
+
+	for _, tt := range errorHandlerTests {
+		c.ErrorHandler = func(err *Error) bool {
+			actual = append(actual, err)
+			return tt.handled
+		}
+
+		if _, err := c.Eval(tt.script); err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			continue
+		}
+
+		output := w.String()
+		w.Reset()
+
+		if len(actual) != 1 {
+			t.Errorf("Context.ErrorHandler('%s'): Expected 1 error, actual %d", tt.script, len(actual))
+		} else if actual[0].Level != tt.level || actual[0].Message != tt.message || actual[0].Line != 1 {
+			t.Errorf("Context.ErrorHandler('%s'): Expected level '%s' and message '%s', actual '%#v'", tt.script, tt.level, tt.message, actual[0])
+		}
+
+		if !strings.Contains(output, tt.output) || (tt.output == "" && output != "") {
+			t.Errorf("Context.ErrorHandler('%s'): Expected output '%s', actual '%s'", tt.script, tt.output, output)
+		}
+
+		actual = nil
+	}
+}
+
+func TestErrorEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#include <errno.h>
+#include <stdbool.h>
+
+#include <main/php.h>
+#include <zend_exceptions.h>
+
+#include "value.h"
+#include "engine.h"
+#include "function.h"
+#include "_cgo_export.h"
+
+// Call Go function corresponding to the active PHP function with arguments
+// passed and return value (if any).
+static void function_call(INTERNAL_FUNCTION_PARAMETERS) {
+	zval args;
+	char *name = (char *) get_active_function_name();
+
+	array_init_size(&args, ZEND_NUM_ARGS());
+
+	if (zend_copy_parameters_array(ZEND_NUM_ARGS(), &args) == FAILURE) {
+		RETVAL_NULL();
+	} else {
+		engine_value *result = engineFunctionCall(name, (void *) &args);
+		if (result == NULL) {
+			RETVAL_NULL();
+		} else {
+			value_copy(return_value, result->internal);
+			_value_destroy(result);
+		}
+	}
+
+	zval_dtor(&args);
+}
+
+// Register global function with the name given, dispatching calls to the Go
+// function registered under the same name. Function names are retained for the
+// lifetime of the function table, as PHP 5 does not copy them.
+void function_define(char *name) {
+	engine_thread_init();
+
+	zend_function_entry functions[] = {
+		{strdup(name), function_call, NULL, 0, 0},
+		{NULL, NULL, NULL, 0, 0}
+	};
+
+	if (zend_register_functions(NULL, functions, NULL, MODULE_PERSISTENT) == FAILURE) {
+		free((char *) functions[0].fname);
+
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+// Set function entry for the name given, dispatching calls to the Go function
+// registered under the same name, as used for module functions.
+void function_entry_set(zend_function_entry *entry, char *name) {
+	zend_function_entry tmp = {strdup(name), function_call, NULL, 0, 0};
+	*entry = tmp;
+}
+
+// Register function with the name given for the current thread, if not already
+// registered. Thread-safe builds of PHP keep separate function tables for each
+// thread, which only contain functions registered during module startup.
+void function_define_thread(char *name) {
+	#ifdef ZTS
+		size_t len = strlen(name);
+		char *lcname = zend_str_tolower_dup(name, len);
+
+		if (!zend_hash_str_exists(CG(function_table), lcname, len)) {
+			function_define(name);
+		}
+
+		efree(lcname);
+	#endif
+}
+
+// Throw exception with the message given from within a function call.
+void function_throw(char *message) {
+	zend_throw_exception(NULL, message, 0);
+}
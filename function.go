@@ -0,0 +1,100 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Type of the built-in error interface, used for detecting error results.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// CallFunc calls the Go function fn with the arguments given, as passed by the
+// PHP context, converting each argument to the type expected by the function,
+// and returns the function's results as a PHP value. Functions returning more
+// than one result have their results returned as an indexed array, while
+// functions returning no results return a nil Value.
+//
+// If the last result returned by the function is a non-nil error, the error is
+// returned in place of any other results.
+func callFunc(fn reflect.Value, args []interface{}) (*Value, error) {
+	t := fn.Type()
+
+	num := t.NumIn()
+	if t.IsVariadic() {
+		num--
+	}
+
+	if len(args) < num {
+		return nil, fmt.Errorf("Expected at least %d arguments, %d given", num, len(args))
+	}
+
+	// Extraneous arguments are ignored for non-variadic functions, as is the case
+	// with PHP functions.
+	if !t.IsVariadic() {
+		args = args[:num]
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var at reflect.Type
+		if i >= num {
+			at = t.In(num).Elem()
+		} else {
+			at = t.In(i)
+		}
+
+		v, err := convertArg(arg, at)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid argument %d: %s", i+1, err)
+		}
+
+		in[i] = v
+	}
+
+	out := fn.Call(in)
+
+	// Process trailing error result, if any.
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			return nil, err
+		}
+
+		out = out[:n-1]
+	}
+
+	// Process results, returning a single value if result slice contains a single
+	// element, otherwise returns a slice of values.
+	var result interface{}
+
+	if len(out) > 1 {
+		t := make([]interface{}, len(out))
+		for i, v := range out {
+			t[i] = v.Interface()
+		}
+
+		result = t
+	} else if len(out) == 1 {
+		result = out[0].Interface()
+	} else {
+		return nil, nil
+	}
+
+	return NewValue(result)
+}
+
+// ConvertArg converts the PHP-derived value given to a value of type t, as
+// expected by a Go function argument. Values are converted as per Value.Decode,
+// and an error is returned for values that cannot be represented in type t,
+// such as fractional or overflowing numbers passed for integer types.
+func convertArg(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if err := decodeValue(arg, v, ""); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFunctionStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+var functionDefineTests = []struct {
+	name     string
+	fn       interface{}
+	script   string
+	expected string
+}{
+	{
+		"go_hello",
+		func(p string) string { return "Hello " + p },
+		"echo go_hello('World');",
+		"Hello World",
+	},
+	{
+		"go_add",
+		func(a, b int) int { return a + b },
+		"echo GO_ADD(1, 2.0);",
+		"3",
+	},
+	{
+		"go_sum",
+		func(n ...float64) (s float64) {
+			for _, v := range n {
+				s += v
+			}
+			return s
+		},
+		"echo go_sum(1, 2.5, 3);",
+		"6.5",
+	},
+	{
+		"go_join",
+		func(s []string, sep string) string { return strings.Join(s, sep) },
+		"echo go_join(['a', 'b', 'c'], ',');",
+		"a,b,c",
+	},
+	{
+		"go_split",
+		func(s string) (string, string) { return s[:1], s[1:] },
+		"list($a, $b) = go_split('wow'); echo $a, ':', $b;",
+		"w:ow",
+	},
+	{
+		"go_bytes",
+		func(b []byte) []byte { return append(b, 0, '!') },
+		"echo bin2hex(go_bytes(\"a\\0b\"));",
+		"6100620021",
+	},
+	{
+		"go_id",
+		func(id testID) testID { return id + 1 },
+		"echo go_id('id-41');",
+		"id-42",
+	},
+	{
+		"go_object",
+		func(o *ObjectValue) string { return o.Class },
+		"class FunctionObject { function __destruct() { echo 'Destroyed '; } } go_object(new FunctionObject); echo 'After';",
+		"Destroyed After",
+	},
+	{
+		"go_none",
+		func() {},
+		"var_dump(go_none());",
+		"NULL\n",
+	},
+	{
+		"go_fail",
+		func(fail bool) (string, error) {
+			if fail {
+				return "", fmt.Errorf("Failed")
+			}
+			return "Success", nil
+		},
+		"echo go_fail(false); try { go_fail(true); } catch (Exception $e) { echo ':', $e->getMessage(); }",
+		"Success:Failed",
+	},
+	{
+		"go_invalid",
+		func(n int) int { return n },
+		"try { go_invalid([]); } catch (Exception $e) { echo 'Invalid'; }",
+		"Invalid",
+	},
+	{
+		"go_overflow",
+		func(n int8) int8 { return n },
+		"echo go_overflow(127); try { go_overflow(128); } catch (Exception $e) { echo ':Overflow'; }",
+		"127:Overflow",
+	},
+	{
+		"go_fraction",
+		func(n int) int { return n },
+		"echo go_fraction(2.0); try { go_fraction(2.5); } catch (Exception $e) { echo ':Fraction'; }",
+		"2:Fraction",
+	},
+	{
+		"go_unsigned",
+		func(n uint) uint { return n },
+		"try { go_unsigned(-1); } catch (Exception $e) { echo 'Negative'; }",
+		"Negative",
+	},
+}
+
+func TestFunctionDefine(t *testing.T) {
+	var w bytes.Buffer
+
+	for _, tt := range functionDefineTests {
+		if err := e.DefineFunc(tt.name, tt.fn); err != nil {
+			t.Errorf("Engine.DefineFunc('%s'): %s", tt.name, err)
+			continue
+		}
+
+		c, _ := e.NewContext()
+		c.Output = &w
+
+		if _, err := c.Eval(tt.script); err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+		}
+
+		c.Destroy()
+
+		if actual := w.String(); actual != tt.expected {
+			t.Errorf("Engine.DefineFunc('%s'): Expected output '%s', actual '%s'", tt.name, tt.expected, actual)
+		}
+
+		w.Reset()
+	}
+}
+
+func TestFunctionEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
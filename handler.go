@@ -0,0 +1,184 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Handler is an http.Handler that serves PHP scripts located under a document
+// root, executing each request in a separate context of the engine given.
+type Handler struct {
+	// Engine is the engine on which request contexts are created.
+	Engine *Engine
+
+	// DocumentRoot is the directory against which request paths are resolved.
+	// If left empty, the current working directory is used.
+	DocumentRoot string
+
+	// IndexFiles contains the file names, in order of preference, tried when a
+	// request path resolves to a directory. If left empty, only 'index.php' is
+	// tried.
+	IndexFiles []string
+}
+
+// ServeHTTP resolves the request path to a script file under the document root
+// and executes it in a new context, writing any headers set and output produced
+// to w. Path components following the script file name are considered path
+// info. Regular files that are not PHP scripts are served as-is, and requests
+// for non-existing files result in a '404 Not Found' response.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	script, name, info, err := h.resolve(r.URL.Path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if filepath.Ext(script) != ".php" {
+		if info != "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, script)
+		return
+	}
+
+	root, err := filepath.Abs(h.root())
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if script, err = filepath.Abs(script); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	vars := map[string]string{
+		"DOCUMENT_ROOT":   root,
+		"SCRIPT_FILENAME": script,
+		"SCRIPT_NAME":     name,
+		"PHP_SELF":        name + info,
+	}
+
+	if info != "" {
+		vars["PATH_INFO"] = info
+		vars["PATH_TRANSLATED"] = filepath.Join(root, filepath.FromSlash(info))
+	}
+
+	ctx, err := h.Engine.NewContextFromRequest(r, vars)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rw := &responseWriter{w: w, ctx: ctx}
+	ctx.Output = rw
+
+	// Execution is interrupted if the client goes away before the script has
+	// completed.
+	err = ctx.ExecContext(r.Context(), script)
+
+	// Any output produced during request shutdown, such as by shutdown functions,
+	// is written before the response is finalized.
+	ctx.Destroy()
+
+	if err != nil && !rw.written {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rw.writeHeader(ctx.Status())
+}
+
+// Root returns the document root for the handler.
+func (h *Handler) root() string {
+	if h.DocumentRoot == "" {
+		return "."
+	}
+
+	return h.DocumentRoot
+}
+
+// Resolve maps the URL path given to a regular file under the document root,
+// returning the file name, the URL path for the file, and any trailing path
+// info. An error is returned if no file could be found for the path.
+func (h *Handler) resolve(p string) (string, string, string, error) {
+	name := h.root()
+	segments := strings.Split(strings.TrimPrefix(path.Clean("/"+p), "/"), "/")
+
+	// Walk path segments until a regular file is found, in which case remaining
+	// segments are returned as path info.
+	for i, s := range segments {
+		if s == "" {
+			break
+		}
+
+		name = filepath.Join(name, filepath.FromSlash(s))
+
+		fi, err := os.Stat(name)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		if fi.Mode().IsRegular() {
+			script := "/" + strings.Join(segments[:i+1], "/")
+			if i == len(segments)-1 {
+				return name, script, "", nil
+			}
+
+			return name, script, "/" + strings.Join(segments[i+1:], "/"), nil
+		} else if !fi.IsDir() {
+			return "", "", "", os.ErrNotExist
+		}
+	}
+
+	// Path resolves to a directory, attempt to find an index file.
+	index := h.IndexFiles
+	if len(index) == 0 {
+		index = []string{"index.php"}
+	}
+
+	for _, f := range index {
+		fi, err := os.Stat(filepath.Join(name, f))
+		if err == nil && fi.Mode().IsRegular() {
+			return filepath.Join(name, f), path.Join(path.Clean("/"+p), f), "", nil
+		}
+	}
+
+	return "", "", "", os.ErrNotExist
+}
+
+// ResponseWriter is an io.Writer used as context output, which writes headers
+// and status set by the context before the first write to the underlying
+// ResponseWriter.
+type responseWriter struct {
+	w       http.ResponseWriter
+	ctx     *Context
+	written bool
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.writeHeader(rw.ctx.Status())
+	return rw.w.Write(p)
+}
+
+func (rw *responseWriter) writeHeader(status int) {
+	if rw.written {
+		return
+	}
+
+	for k, v := range rw.ctx.Header {
+		rw.w.Header()[k] = v
+	}
+
+	rw.w.WriteHeader(status)
+	rw.written = true
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandlerStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+var handlerFiles = map[string]string{
+	"index.php":       "<?php echo 'Index';",
+	"hello.php":       "<?php header('X-Testing: Hello'); echo 'Hello World';",
+	"empty.php":       "<?php header('X-Testing: Empty');",
+	"info.php":        "<?php echo $_SERVER['SCRIPT_NAME'], ':', $_SERVER['PATH_INFO'], ':', $_GET['q'];",
+	"missing.php":     "<?php http_response_code(404); echo 'Missing';",
+	"redirect.php":    "<?php header('Location: /index.php');",
+	"static.txt":      "Static",
+	"sub/index.php":   "<?php echo 'Sub Index';",
+	"sub/default.php": "<?php echo 'Sub Default';",
+}
+
+var handlerTests = []struct {
+	path   string
+	index  []string
+	status int
+	body   string
+	header string
+}{
+	{"/", nil, http.StatusOK, "Index", ""},
+	{"/index.php", nil, http.StatusOK, "Index", ""},
+	{"/hello.php", nil, http.StatusOK, "Hello World", "Hello"},
+	{"/hello.php/path/info", nil, http.StatusOK, "Hello World", "Hello"},
+	{"/empty.php", nil, http.StatusOK, "", "Empty"},
+	{"/info.php/path/info?q=query", nil, http.StatusOK, "/info.php:/path/info:query", ""},
+	{"/missing.php", nil, http.StatusNotFound, "Missing", ""},
+	{"/redirect.php", nil, http.StatusFound, "", ""},
+	{"/static.txt", nil, http.StatusOK, "Static", ""},
+	{"/static.txt/path/info", nil, http.StatusNotFound, "404 page not found\n", ""},
+	{"/sub/", nil, http.StatusOK, "Sub Index", ""},
+	{"/sub", []string{"default.php", "index.php"}, http.StatusOK, "Sub Default", ""},
+	{"/notfound.php", nil, http.StatusNotFound, "404 page not found\n", ""},
+	{"/sub/missing/index.php", nil, http.StatusNotFound, "404 page not found\n", ""},
+}
+
+func TestHandlerServeHTTP(t *testing.T) {
+	root, err := ioutil.TempDir("", "handler")
+	if err != nil {
+		t.Fatalf("Could not create temporary directory for testing: %s", err)
+	}
+
+	defer os.RemoveAll(root)
+
+	for name, contents := range handlerFiles {
+		name = filepath.Join(root, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("Could not create temporary directory for testing: %s", err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatalf("Could not create temporary file '%s' for testing: %s", name, err)
+		}
+	}
+
+	for _, tt := range handlerTests {
+		h := &Handler{Engine: e, DocumentRoot: root, IndexFiles: tt.index}
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+
+		if w.Code != tt.status {
+			t.Errorf("Handler.ServeHTTP('%s'): Expected status '%d', actual '%d'", tt.path, tt.status, w.Code)
+		}
+
+		if actual := w.Body.String(); actual != tt.body {
+			t.Errorf("Handler.ServeHTTP('%s'): Expected body '%s', actual '%s'", tt.path, tt.body, actual)
+		}
+
+		if actual := w.Header().Get("X-Testing"); actual != tt.header {
+			t.Errorf("Handler.ServeHTTP('%s'): Expected header '%s', actual '%s'", tt.path, tt.header, actual)
+		}
+	}
+
+	if len(e.contexts) != 0 {
+		t.Errorf("Handler.ServeHTTP(): `Engine.contexts` length is %d, should be 0", len(e.contexts))
+	}
+}
+
+func TestHandlerEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
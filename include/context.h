@@ -6,13 +6,48 @@
 #define __CONTEXT_H__
 
 typedef struct _engine_context {
+	char *request_method;
+	char *request_uri;
+	char *query_string;
+	char *content_type;
+	long content_length;
+	char *cookie_data;
+	char *path_translated;
+	int  proto_num;
+
+	// Set when execution for the context is to be aborted, possibly from a thread
+	// other than the one executing the context.
+	volatile int interrupted;
+
+	// The response code for the request, kept per thread for thread-safe builds
+	// of PHP, and therefore captured for use by other threads.
+	int *response_code;
+
+	#if PHP_VERSION_ID >= 70100
+		zend_bool *vm_interrupt;
+	#endif
+
+	#ifdef ZTS
+		THREAD_T thread_id;
+	#endif
 } engine_context;
 
 engine_context *context_new();
+void context_set_request(engine_context *context, char *method, char *uri, char *query, char *content_type, long content_length, char *cookies, char *path, int proto);
+void context_startup(engine_context *context);
 void context_exec(engine_context *context, char *filename);
-void *context_eval(engine_context *context, char *script);
+void context_exec_data(engine_context *context, char *name, char *data, size_t len);
+void *context_eval(engine_context *context, char *script, size_t len);
 void context_bind(engine_context *context, char *name, void *value);
+void context_set_ini(engine_context *context, char *name, char *value);
+engine_context *context_current();
+int context_is_local(engine_context *context);
+void context_interrupt_init();
+void context_interrupt(engine_context *context);
+void context_interrupt_clear(engine_context *context);
+int context_get_status(engine_context *context);
 void context_destroy(engine_context *context);
+void context_free(engine_context *context);
 
 #include "_context.h"
 
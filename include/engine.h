@@ -8,8 +8,12 @@
 typedef struct _php_engine {
 } php_engine;
 
-php_engine *engine_init(void);
+php_engine *engine_init(char *ini_entries);
 void engine_shutdown(php_engine *engine);
+void engine_thread_init(void);
+int engine_thread_safe(void);
+engine_value *engine_extensions(void);
+void engine_register_variable(char *key, char *value, void *track_vars_array);
 
 #include "_engine.h"
 
@@ -0,0 +1,16 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef __ERROR_H__
+#define __ERROR_H__
+
+void error_init(void);
+void error_activate(void);
+void error_exception(zval *ex);
+void error_exception_clear(void);
+void error_shutdown(void);
+
+#include "_error.h"
+
+#endif
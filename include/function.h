@@ -0,0 +1,13 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef __FUNCTION_H__
+#define __FUNCTION_H__
+
+void function_define(char *name);
+void function_define_thread(char *name);
+void function_entry_set(zend_function_entry *entry, char *name);
+void function_throw(char *message);
+
+#endif
@@ -0,0 +1,28 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef __MODULE_H__
+#define __MODULE_H__
+
+typedef struct _engine_module {
+	zend_module_entry entry;
+	zend_function_entry *functions;
+	int function_count;
+	void *ini_entries;
+	int ini_count;
+	int number;
+} engine_module;
+
+engine_module *module_new(char *name, char *version);
+void module_function_add(engine_module *module, char *name);
+void module_ini_add(engine_module *module, char *name, char *value);
+void module_constant_register(char *name, engine_value *value, int number);
+void module_constant_define(char *name, engine_value *value);
+bool module_constant_exists(char *name);
+zend_module_entry *module_entries(unsigned int *count);
+void module_free_all(void);
+
+#include "_module.h"
+
+#endif
@@ -0,0 +1,14 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef __MOUNT_H__
+#define __MOUNT_H__
+
+void mount_init(void);
+int mount_exists(char *scheme);
+void mount_register(char *scheme);
+
+#include "_mount.h"
+
+#endif
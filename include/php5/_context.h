@@ -6,6 +6,8 @@
 #define ___CONTEXT_H___
 
 static void _context_bind(char *name, zval *value);
+static void _context_set_opened_path(zend_file_handle *handle, char *name);
 static void _context_eval(zend_op_array *op, zval *ret);
+static int _context_set_ini(char *name, char *value);
 
 #endif
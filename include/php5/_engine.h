@@ -6,5 +6,7 @@
 #define ___ENGINE_H___
 
 static int _engine_ub_write(const char *str, uint len);
+static int _engine_read_post(char *buffer, uint count_bytes);
+static void _engine_extensions(zval *list);
 
 #endif
@@ -0,0 +1,15 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef ___ERROR_H___
+#define ___ERROR_H___
+
+static void _error_handler_init(zend_internal_function *func, void (*handler)(INTERNAL_FUNCTION_PARAMETERS));
+static void _error_handler_set(zend_internal_function *func);
+static void _error_handler_destroy(zend_internal_function *func);
+
+static void _error_exception(engine_context *context, zval *ex);
+static void _error_exception_clear(void);
+
+#endif
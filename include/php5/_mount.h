@@ -0,0 +1,12 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef ___MOUNT_H___
+#define ___MOUNT_H___
+
+static void _mount_init(void);
+static int _mount_exists(char *scheme);
+static int _mount_register(char *scheme);
+
+#endif
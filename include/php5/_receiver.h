@@ -18,6 +18,7 @@ static zend_object_value _receiver_init(zend_class_entry *class_type);
 static void _receiver_destroy(char *name);
 
 static engine_receiver *_receiver_this(zval *object);
+static void _receiver_object(engine_receiver *rcvr, zval *object);
 static void _receiver_handlers_set(zend_object_handlers *handlers);
 char *_receiver_get_name(engine_receiver *rcvr);
 
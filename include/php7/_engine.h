@@ -6,5 +6,7 @@
 #define ___ENGINE_H___
 
 static size_t _engine_ub_write(const char *str, size_t len);
+static size_t _engine_read_post(char *buffer, size_t count_bytes);
+static void _engine_extensions(zval *list);
 
 #endif
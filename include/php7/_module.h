@@ -0,0 +1,15 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#ifndef ___MODULE_H___
+#define ___MODULE_H___
+
+static int _module_ini_add(engine_module *module, char *name, char *value);
+static void _module_ini_register(engine_module *module);
+static void _module_ini_unregister(int number);
+static void _module_ini_free(engine_module *module);
+static int _module_constant_register(char *name, zval *value, int number);
+static int _module_constant_exists(char *name);
+
+#endif
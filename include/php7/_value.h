@@ -9,13 +9,21 @@ zval *_value_init();
 void _value_destroy(engine_value *val);
 
 int _value_truth(zval *val);
-void _value_set_string(zval **val, char *str);
+void _value_set_string(zval **val, char *str, size_t len);
 
 static int _value_current_key_get(HashTable *ht, zend_string **str_index, zend_ulong *num_index);
 static void _value_current_key_set(HashTable *ht, engine_value *val);
 
 static void _value_array_next_get(HashTable *ht, engine_value *val);
 static void _value_array_index_get(HashTable *ht, unsigned long index, engine_value *val);
-static void _value_array_key_get(HashTable *ht, char *key, engine_value *val);
+static void _value_array_key_set(zval *arr, const char *key, size_t len, zval *val);
+static void _value_array_key_get(HashTable *ht, char *key, size_t len, engine_value *val);
+
+static int _value_call(zval *object, char *name, zval *args, engine_value *result);
+static int _value_object_new(char *class, zval *args, engine_value *result);
+static void _value_object_property_get(zval *object, char *key, engine_value *result);
+static void _value_object_property_update(zval *object, char *key, zval *value);
+static const char *_value_object_class_name(zval *object);
+static bool _value_object_instance_of(zval *object, char *class);
 
 #endif
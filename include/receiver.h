@@ -7,11 +7,19 @@
 
 typedef struct _engine_receiver {
 	zend_object obj;
+
+	#if PHP_MAJOR_VERSION < 7
+		zend_object_handle handle;
+	#endif
 } engine_receiver;
 
 void receiver_define(char *name);
+void receiver_define_thread(char *name);
 void receiver_destroy(char *name);
 
+engine_receiver *receiver_from_value(engine_value *val);
+void receiver_value_set(engine_receiver *rcvr, engine_value *val);
+
 #include "_receiver.h"
 
 #endif
@@ -29,27 +29,34 @@ void value_set_null(engine_value *val);
 void value_set_long(engine_value *val, long int num);
 void value_set_double(engine_value *val, double num);
 void value_set_bool(engine_value *val, bool status);
-void value_set_string(engine_value *val, char *str);
+void value_set_string(engine_value *val, char *str, size_t len);
 void value_set_array(engine_value *val, unsigned int size);
 void value_set_object(engine_value *val);
 void value_set_zval(engine_value *val, zval *src);
 
+engine_value *value_call(engine_value *obj, char *name, engine_value *args);
+engine_value *value_object_new(char *class, engine_value *args);
+engine_value *value_object_property_get(engine_value *obj, char *key);
+void value_object_property_update(engine_value *obj, char *key, engine_value *val);
+char *value_object_class_name(engine_value *obj);
+bool value_object_instance_of(engine_value *obj, char *class);
+
 void value_array_next_set(engine_value *arr, engine_value *val);
 void value_array_index_set(engine_value *arr, unsigned long idx, engine_value *val);
-void value_array_key_set(engine_value *arr, const char *key, engine_value *val);
+void value_array_key_set(engine_value *arr, const char *key, size_t len, engine_value *val);
 void value_object_property_set(engine_value *obj, const char *key, engine_value *val);
 
 int value_get_long(engine_value *val);
 double value_get_double(engine_value *val);
 bool value_get_bool(engine_value *val);
-char *value_get_string(engine_value *val);
+char *value_get_string(engine_value *val, size_t *len);
 
 unsigned int value_array_size(engine_value *arr);
 engine_value *value_array_keys(engine_value *arr);
 void value_array_reset(engine_value *arr);
 engine_value *value_array_next_get(engine_value *arr);
 engine_value *value_array_index_get(engine_value *arr, unsigned long idx);
-engine_value *value_array_key_get(engine_value *arr, char *key);
+engine_value *value_array_key_get(engine_value *arr, char *key, size_t len);
 
 #include "_value.h"
 
@@ -0,0 +1,702 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// Messages exchanged between an isolated engine and its worker processes are
+// sent over a connected pair of Unix sockets, and are framed as follows, with
+// integers in network (big-endian) byte order:
+//
+//	+---------+-----------------+------------------+
+//	| type    | length          | payload          |
+//	| 1 byte  | 4 bytes, uint32 | length bytes     |
+//	+---------+-----------------+------------------+
+//
+// The payload is a self-contained gob stream encoding a single wireMessage, of
+// which only the fields relevant to the message type are set. Every request
+// sent by the engine is answered by exactly one msgResult message, which may
+// be preceded by any number of msgOutput, msgLog and msgError messages; each
+// msgError message is in turn answered by a msgHandled message. The first
+// request sent to a worker process is always msgStart.
+const (
+	// Requests sent by the engine.
+	msgNewContext     byte = 1 // Request, Server
+	msgBind           byte = 2 // Name, Value
+	msgExec           byte = 3 // Name, Handler
+	msgEval           byte = 4 // Name, Handler
+	msgCall           byte = 5 // Name, Args, Handler
+	msgDefineConstant byte = 6 // Name, Value
+	msgDestroy        byte = 7
+	msgHandled        byte = 8  // Handled
+	msgSetIni         byte = 9  // Name, Value
+	msgExecData       byte = 10 // Name, Data, Handler
+	msgStart          byte = 11 // Config
+
+	// Responses sent by worker processes.
+	msgResult byte = 64 // Value, Header, Status, Error, Exception, Message
+	msgOutput byte = 65 // Data, Header, Status
+	msgLog    byte = 66 // Data
+	msgError  byte = 67 // Error
+)
+
+// The maximum payload length accepted for a single message.
+const maxMessageLength = 1 << 30
+
+// WireMessage represents a message exchanged with a worker process.
+type wireMessage struct {
+	Name    string
+	Value   interface{}
+	Args    []interface{}
+	Data    []byte
+	Handler bool
+	Handled bool
+
+	Request *wireRequest
+	Server  map[string]string
+	Header  http.Header
+	Status  int
+	Config  *workerConfig
+
+	Error     *Error
+	Exception *Exception
+	Message   string
+}
+
+// WireRequest represents the HTTP request a context is created from.
+type wireRequest struct {
+	Method        string
+	URL           string
+	Proto         string
+	Header        http.Header
+	Host          string
+	RemoteAddr    string
+	ContentLength int64
+	Body          []byte
+}
+
+// SetError sets the error fields for the message from the error given.
+func (m *wireMessage) setError(err error) {
+	switch err := err.(type) {
+	case nil:
+	case *Error:
+		m.Error = err
+	case *Exception:
+		m.Exception = err
+	default:
+		m.Message = err.Error()
+	}
+}
+
+// Err returns the error represented by the message, if any.
+func (m *wireMessage) err() error {
+	switch {
+	case m.Error != nil:
+		return m.Error
+	case m.Exception != nil:
+		return m.Exception
+	case m.Message != "":
+		return fmt.Errorf("%s", m.Message)
+	}
+
+	return nil
+}
+
+// WriteMessage writes a single framed message of the type given to w.
+func writeMessage(w io.Writer, typ byte, msg *wireMessage) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(msg); err != nil {
+		return err
+	}
+
+	header := make([]byte, 5)
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(payload.Len()))
+
+	if _, err := w.Write(append(header, payload.Bytes()...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadMessage reads a single framed message from r, and returns its type along
+// with the decoded payload.
+func readMessage(r io.Reader) (byte, *wireMessage, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxMessageLength {
+		return 0, nil, fmt.Errorf("Message length %d exceeds limit", length)
+	}
+
+	var msg wireMessage
+	if err := gob.NewDecoder(io.LimitReader(r, int64(length))).Decode(&msg); err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], &msg, nil
+}
+
+// Isolation holds the worker processes for an isolated engine.
+type isolation struct {
+	// The configuration worker engines are created with.
+	config *workerConfig
+
+	// Idle workers, of which nil entries are to be started when next needed.
+	idle chan *isolatedWorker
+
+	// All running workers, stopped when the engine is destroyed.
+	workers map[*isolatedWorker]bool
+	mu      sync.Mutex
+}
+
+// NewIsolated initializes a PHP engine instance which executes contexts in up
+// to workers separate child processes, isolating the running program from any
+// crashes occurring within PHP. Contexts are used in the same way as for
+// engines returned by New, and are each bound to a single worker process for
+// their lifetime; NewContext blocks until a worker is available. Workers that
+// exit unexpectedly are restarted when next needed, while any context using the
+// worker at the time returns an error for subsequent operations.
+//
+// Worker processes are started by running the program's executable, and the
+// program is required to call ServeWorker on startup, which creates the engine
+// used by each worker process. Engine definitions (such as those made by
+// Engine.Define or Engine.DefineFunc) are not passed on to worker processes,
+// and need to be made by the function passed to ServeWorker.
+//
+// Values passed to and returned from isolated contexts are copied between
+// processes, and are limited to the kinds of values returned by
+// Value.Interface. Objects are copied as ObjectValue instances without a handle
+// to the live PHP object, and are bound back to PHP as associative arrays of
+// their properties; methods cannot be called on objects returned from isolated
+// contexts. For the same reason, Context.New is not supported for isolated
+// contexts, and returns an error.
+func NewIsolated(workers int) (*Engine, error) {
+	return NewIsolatedWithConfig(Config{}, workers)
+}
+
+// NewIsolatedWithConfig initializes an isolated PHP engine instance in the same
+// way as NewIsolated, using the configuration given. The configuration applies
+// to the engine in the running program as well as to the engines created by
+// worker processes, except for any modules set, which are not passed on to
+// worker processes.
+func NewIsolatedWithConfig(config Config, workers int) (*Engine, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("Cannot create isolated engine with %d workers", workers)
+	} else if err := checkWorker(); err != nil {
+		return nil, err
+	}
+
+	e, err := NewWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	e.isolation = &isolation{
+		config:  newWorkerConfig(config),
+		idle:    make(chan *isolatedWorker, workers),
+		workers: make(map[*isolatedWorker]bool),
+	}
+
+	for i := 0; i < workers; i++ {
+		e.isolation.idle <- nil
+	}
+
+	return e, nil
+}
+
+// NewContext creates a new context on an available worker process, starting
+// the worker if needed. Contexts are created on a different worker if the one
+// first acquired has exited unexpectedly.
+func (i *isolation) newContext(r *http.Request, server map[string]string) (*Context, error) {
+	req := &wireMessage{Server: server}
+
+	if r != nil {
+		wr, err := newWireRequest(r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read request for isolated context: %s", err)
+		}
+
+		req.Request = wr
+	}
+
+	for attempt := 0; ; attempt++ {
+		w, err := i.acquire()
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := &Context{
+			Header: make(http.Header),
+			values: make([]*Value, 0),
+			worker: w,
+		}
+
+		if _, err = ctx.remote(msgNewContext, req); err == nil {
+			return ctx, nil
+		}
+
+		ctx.Destroy()
+
+		if !w.exited() || attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+// Acquire returns the next idle worker, waiting for one to become available if
+// needed, and starts a new worker in place of any that have exited.
+func (i *isolation) acquire() (*isolatedWorker, error) {
+	w := <-i.idle
+	if w != nil && !w.exited() {
+		return w, nil
+	}
+
+	w, err := i.start()
+	if err != nil {
+		i.idle <- nil
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Release returns the worker given to the set of idle workers.
+func (i *isolation) release(w *isolatedWorker) {
+	if w.exited() {
+		i.mu.Lock()
+		delete(i.workers, w)
+		i.mu.Unlock()
+
+		i.idle <- nil
+		return
+	}
+
+	i.idle <- w
+}
+
+// Start starts a new worker process, connected to the engine over a Unix socket
+// pair, and waits for the worker process to create its engine.
+func (i *isolation) start() (*isolatedWorker, error) {
+	name, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start isolated worker: %s", err)
+	}
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start isolated worker: %s", err)
+	}
+
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+
+	local := os.NewFile(uintptr(fds[0]), "isolated-worker")
+	remote := os.NewFile(uintptr(fds[1]), "isolated-engine")
+
+	// The worker end of the socket pair is only used by the worker process.
+	defer remote.Close()
+
+	conn, err := net.FileConn(local)
+	local.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start isolated worker: %s", err)
+	}
+
+	cmd := exec.Command(name)
+	cmd.Env = append(os.Environ(), workerEnv+"="+isolatedWorkerKind)
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{remote}
+
+	if err := cmd.Start(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Unable to start isolated worker: %s", err)
+	}
+
+	w := &isolatedWorker{isolation: i, cmd: cmd, conn: conn, done: make(chan struct{})}
+
+	if err := w.startEngine(i.config); err != nil {
+		w.kill()
+		w.stop()
+		return nil, fmt.Errorf("Unable to start isolated worker: %s", err)
+	}
+
+	i.mu.Lock()
+	i.workers[w] = true
+	i.mu.Unlock()
+
+	return w, nil
+}
+
+// Stop stops all running workers, waiting for these to exit.
+func (i *isolation) stop() {
+	i.mu.Lock()
+	workers := i.workers
+	i.workers = make(map[*isolatedWorker]bool)
+	i.mu.Unlock()
+
+	for w := range workers {
+		w.stop()
+	}
+}
+
+// IsolatedWorker represents a worker process for an isolated engine.
+type isolatedWorker struct {
+	isolation *isolation
+	cmd       *exec.Cmd
+	conn      net.Conn
+	once      sync.Once
+
+	// Closed once the worker process has exited.
+	done chan struct{}
+}
+
+// Stop closes the connection to the worker, which signals the worker process to
+// exit, and waits for the worker process to exit.
+func (w *isolatedWorker) stop() {
+	w.once.Do(func() {
+		w.conn.Close()
+		w.cmd.Wait()
+		close(w.done)
+	})
+}
+
+// StartEngine sends the configuration given to the worker process, and waits
+// for the worker process to create its engine.
+func (w *isolatedWorker) startEngine(config *workerConfig) error {
+	if err := writeMessage(w.conn, msgStart, &wireMessage{Config: config}); err != nil {
+		return err
+	}
+
+	typ, msg, err := readMessage(w.conn)
+	if err != nil {
+		return err
+	} else if typ != msgResult {
+		return fmt.Errorf("Unexpected message type %d", typ)
+	}
+
+	return msg.err()
+}
+
+// Exited returns whether the worker process has exited.
+func (w *isolatedWorker) exited() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Kill terminates the worker process immediately.
+func (w *isolatedWorker) kill() error {
+	return w.cmd.Process.Kill()
+}
+
+// Remote sends the request given to the worker process for the context, and
+// processes messages received until the request is answered. Output and errors
+// are passed on to the context's writers and error handler, while headers and
+// status are kept in sync with the worker context.
+func (c *Context) remote(typ byte, req *wireMessage) (*wireMessage, error) {
+	w := c.worker
+	if w == nil || w.exited() {
+		return nil, fmt.Errorf("Worker process for context is no longer available")
+	}
+
+	if err := writeMessage(w.conn, typ, req); err != nil {
+		w.stop()
+		return nil, fmt.Errorf("Worker process for context exited unexpectedly: %s", err)
+	}
+
+	for {
+		typ, msg, err := readMessage(w.conn)
+		if err != nil {
+			w.stop()
+			return nil, fmt.Errorf("Worker process for context exited unexpectedly: %s", err)
+		}
+
+		switch typ {
+		case msgOutput:
+			c.Header, c.status = msg.Header, msg.Status
+			if c.Output != nil {
+				c.Output.Write(msg.Data)
+			}
+		case msgLog:
+			if c.Log != nil {
+				c.Log.Write(msg.Data)
+			}
+		case msgError:
+			handled := c.ErrorHandler != nil && c.ErrorHandler(msg.Error)
+			if err := writeMessage(w.conn, msgHandled, &wireMessage{Handled: handled}); err != nil {
+				w.stop()
+				return nil, fmt.Errorf("Worker process for context exited unexpectedly: %s", err)
+			}
+		case msgResult:
+			c.Header, c.status = msg.Header, msg.Status
+			return msg, msg.err()
+		}
+	}
+}
+
+// RemoteValue sends the request given to the worker process for the context,
+// and returns the value returned in response as a local Value.
+func (c *Context) remoteValue(typ byte, req *wireMessage) (*Value, error) {
+	msg, err := c.remote(typ, req)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := NewValue(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	c.values = append(c.values, val)
+
+	return val, nil
+}
+
+// DestroyRemote tears down the context on its worker process, if still running,
+// and releases the worker for use by other contexts.
+func (c *Context) destroyRemote() {
+	for _, v := range c.values {
+		v.Destroy()
+	}
+
+	c.values = nil
+
+	w := c.worker
+	if !w.exited() {
+		c.remote(msgDestroy, &wireMessage{})
+	}
+
+	w.isolation.release(w)
+	c.worker = nil
+}
+
+// NewWireRequest returns a copy of the HTTP request given for transfer to a
+// worker process, including the full request body.
+func newWireRequest(r *http.Request) (*wireRequest, error) {
+	wr := &wireRequest{
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		Proto:         r.Proto,
+		Header:        r.Header,
+		Host:          r.Host,
+		RemoteAddr:    r.RemoteAddr,
+		ContentLength: r.ContentLength,
+	}
+
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		wr.Body = body
+	}
+
+	return wr, nil
+}
+
+// HTTPRequest returns the HTTP request represented by the request given.
+func (wr *wireRequest) httpRequest() (*http.Request, error) {
+	r, err := http.NewRequest(wr.Method, wr.URL, bytes.NewReader(wr.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Proto = wr.Proto
+	r.ProtoMajor, r.ProtoMinor, _ = http.ParseHTTPVersion(wr.Proto)
+	r.Header = wr.Header
+	r.Host = wr.Host
+	r.RemoteAddr = wr.RemoteAddr
+	r.ContentLength = wr.ContentLength
+
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	return r, nil
+}
+
+// PortableValue returns the value given as converted to and from a PHP value,
+// which can be transferred to worker processes.
+func portableValue(val interface{}) (interface{}, error) {
+	v, err := NewValue(val)
+	if err != nil {
+		return nil, err
+	}
+
+	defer v.Destroy()
+
+	val = v.Interface()
+	ReleaseObjects(val)
+
+	return val, nil
+}
+
+// WorkerWriter is an io.Writer used as context output in worker processes,
+// which sends data written to the engine.
+type workerWriter struct {
+	conn net.Conn
+	typ  byte
+	ctx  *Context
+}
+
+func (w *workerWriter) Write(p []byte) (int, error) {
+	msg := &wireMessage{Data: p}
+	if w.typ == msgOutput {
+		msg.Header, msg.Status = w.ctx.Header, w.ctx.Status()
+	}
+
+	if err := writeMessage(w.conn, w.typ, msg); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// ServeIsolatedWorker creates the engine for the worker process from the
+// configuration received from the engine in the parent process, and executes
+// requests received until the connection is closed. The exit code for the
+// worker process is returned.
+func serveIsolatedWorker(start func(config Config) (*Engine, error), conn net.Conn) int {
+	var ctx *Context
+
+	typ, req, err := readMessage(conn)
+	if err != nil {
+		return 1
+	} else if typ != msgStart || req.Config == nil {
+		writeMessage(conn, msgResult, &wireMessage{Message: "Worker process not started"})
+		return 1
+	}
+
+	e, err := startWorker(start, req.Config)
+	if err != nil {
+		writeMessage(conn, msgResult, &wireMessage{Message: err.Error()})
+		return 1
+	}
+
+	if err := writeMessage(conn, msgResult, &wireMessage{}); err != nil {
+		e.Destroy()
+		return 1
+	}
+
+	for {
+		typ, req, err := readMessage(conn)
+		if err != nil {
+			break
+		}
+
+		resp := &wireMessage{}
+
+		if ctx == nil && typ != msgNewContext {
+			resp.setError(fmt.Errorf("No active context for worker process"))
+		} else if ctx != nil {
+			// Forward errors to the engine only if the context there handles them.
+			ctx.ErrorHandler = nil
+			if req.Handler {
+				ctx.ErrorHandler = func(e *Error) bool {
+					if writeMessage(conn, msgError, &wireMessage{Error: e}) != nil {
+						return false
+					}
+
+					typ, msg, err := readMessage(conn)
+					return err == nil && typ == msgHandled && msg.Handled
+				}
+			}
+		}
+
+		switch {
+		case resp.err() != nil:
+		case typ == msgNewContext:
+			var r *http.Request
+			if req.Request != nil {
+				if r, err = req.Request.httpRequest(); err != nil {
+					resp.setError(err)
+					break
+				}
+			}
+
+			if ctx, err = e.newContext(r, req.Server); err != nil {
+				resp.setError(err)
+				break
+			}
+
+			ctx.Output = &workerWriter{conn: conn, typ: msgOutput, ctx: ctx}
+			ctx.Log = &workerWriter{conn: conn, typ: msgLog, ctx: ctx}
+		case typ == msgBind:
+			resp.setError(ctx.Bind(req.Name, req.Value))
+		case typ == msgExec:
+			resp.setError(ctx.Exec(req.Name))
+		case typ == msgExecData:
+			resp.setError(ctx.execData(req.Name, req.Data))
+		case typ == msgEval, typ == msgCall:
+			var val *Value
+			if typ == msgEval {
+				val, err = ctx.Eval(req.Name)
+			} else {
+				val, err = ctx.Call(req.Name, req.Args...)
+			}
+
+			if err == nil && val != nil {
+				resp.Value = val.Interface()
+				ReleaseObjects(resp.Value)
+			}
+
+			resp.setError(err)
+		case typ == msgDefineConstant:
+			resp.setError(ctx.DefineConstant(req.Name, req.Value))
+		case typ == msgSetIni:
+			value, _ := req.Value.(string)
+			resp.setError(ctx.SetIni(req.Name, value))
+		case typ == msgDestroy:
+			ctx.Destroy()
+			resp.Header, resp.Status = ctx.Header, ctx.Status()
+			ctx = nil
+		default:
+			resp.setError(fmt.Errorf("Unknown message type %d", typ))
+		}
+
+		if ctx != nil {
+			resp.Header, resp.Status = ctx.Header, ctx.Status()
+		}
+
+		if err := writeMessage(conn, msgResult, resp); err != nil {
+			if ctx != nil {
+				ctx.Destroy()
+			}
+
+			e.Destroy()
+			return 1
+		}
+	}
+
+	if ctx != nil {
+		ctx.Destroy()
+	}
+
+	e.Destroy()
+	return 0
+}
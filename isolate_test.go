@@ -0,0 +1,279 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestIsolatedStart(t *testing.T) {
+	e, _ = NewIsolated(2)
+	t.SkipNow()
+}
+
+var isolatedEvalTests = []struct {
+	bindings map[string]interface{}
+	script   string
+	output   string
+	status   int
+	header   string
+	expected interface{}
+}{
+	{
+		nil,
+		"echo 'Hello World';",
+		"Hello World",
+		http.StatusOK,
+		"",
+		nil,
+	},
+	{
+		map[string]interface{}{"a": 1, "b": 2.5},
+		"header('X-Testing: Hello'); http_response_code(201); return $a + $b;",
+		"",
+		http.StatusCreated,
+		"Hello",
+		float64(3.5),
+	},
+	{
+		map[string]interface{}{"s": []string{"a", "b"}},
+		"return ['list' => $s, 'count' => count($s)];",
+		"",
+		http.StatusOK,
+		"",
+		map[string]interface{}{"list": []interface{}{"a", "b"}, "count": int64(2)},
+	},
+}
+
+func TestIsolatedEval(t *testing.T) {
+	var w bytes.Buffer
+
+	for _, tt := range isolatedEvalTests {
+		c, err := e.NewContext()
+		if err != nil {
+			t.Fatalf("NewContext(): %s", err)
+		}
+
+		c.Output = &w
+
+		for name, val := range tt.bindings {
+			if err := c.Bind(name, val); err != nil {
+				t.Errorf("Context.Bind('%s'): %s", name, err)
+			}
+		}
+
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			c.Destroy()
+			continue
+		}
+
+		if actual := val.Interface(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Context.Eval('%s'): Expected value '%#v', actual '%#v'", tt.script, tt.expected, actual)
+		}
+
+		c.Destroy()
+
+		if actual := w.String(); actual != tt.output {
+			t.Errorf("Context.Eval('%s'): Expected output '%s', actual '%s'", tt.script, tt.output, actual)
+		}
+
+		if actual := c.Status(); actual != tt.status {
+			t.Errorf("Context.Eval('%s'): Expected status '%d', actual '%d'", tt.script, tt.status, actual)
+		}
+
+		if actual := c.Header.Get("X-Testing"); actual != tt.header {
+			t.Errorf("Context.Eval('%s'): Expected header '%s', actual '%s'", tt.script, tt.header, actual)
+		}
+
+		w.Reset()
+	}
+}
+
+func TestIsolatedErrors(t *testing.T) {
+	var w bytes.Buffer
+	var handled []*Error
+
+	c, err := e.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext(): %s", err)
+	}
+
+	defer c.Destroy()
+
+	c.Output = &w
+	c.ErrorHandler = func(err *Error) bool {
+		handled = append(handled, err)
+		return true
+	}
+
+	if _, err := c.Eval("trigger_error('Test Notice'); echo 'Done';"); err != nil {
+		t.Errorf("Context.Eval(): %s", err)
+	}
+
+	if len(handled) != 1 || handled[0].Message != "Test Notice" || w.String() != "Done" {
+		t.Errorf("Context.ErrorHandler(): Unexpected errors '%#v' with output '%s'", handled, w.String())
+	}
+
+	if _, err := c.Eval("throw new Exception('Test Exception');"); err == nil {
+		t.Errorf("Context.Eval(): Expected exception, got none")
+	} else if ex, ok := err.(*Exception); !ok || ex.Message != "Test Exception" {
+		t.Errorf("Context.Eval(): Expected exception, actual '%#v'", err)
+	}
+
+	if val, err := c.Call("strtoupper", "hello"); err != nil {
+		t.Errorf("Context.Call(): %s", err)
+	} else if val.String() != "HELLO" {
+		t.Errorf("Context.Call(): Expected 'HELLO', actual '%s'", val.String())
+	}
+
+	if _, err := c.New("stdClass"); err == nil {
+		t.Errorf("Context.New(): Incorrectly created object in isolated context")
+	}
+}
+
+func TestIsolatedCrash(t *testing.T) {
+	c, err := e.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext(): %s", err)
+	}
+
+	if _, err := c.Eval("test_crash();"); err == nil {
+		t.Errorf("Context.Eval(): Expected error for crashed worker, got none")
+	}
+
+	if _, err := c.Eval("return 1;"); err == nil {
+		t.Errorf("Context.Eval(): Expected error for crashed worker, got none")
+	}
+
+	c.Destroy()
+
+	// Workers are restarted in place of crashed workers.
+	for i := 0; i < 2; i++ {
+		c, err := e.NewContext()
+		if err != nil {
+			t.Fatalf("NewContext(): %s", err)
+		}
+
+		val, err := c.Eval("return 1 + 1;")
+		if err != nil {
+			t.Errorf("Context.Eval(): %s", err)
+		} else if val.Int() != 2 {
+			t.Errorf("Context.Eval(): Expected '2', actual '%d'", val.Int())
+		}
+
+		c.Destroy()
+	}
+}
+
+func TestIsolatedConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			var w bytes.Buffer
+
+			c, err := e.NewContext()
+			if err != nil {
+				t.Errorf("NewContext(): %s", err)
+				return
+			}
+
+			c.Output = &w
+			c.Bind("i", i)
+
+			val, err := c.Eval("echo $i; return $i * 2;")
+			if err != nil {
+				t.Errorf("Context.Eval(%d): %s", i, err)
+			} else if w.String() != fmt.Sprintf("%d", i) || val.Int() != int64(i*2) {
+				t.Errorf("Context.Eval(%d): Unexpected output '%s' and value '%d'", i, w.String(), val.Int())
+			}
+
+			c.Destroy()
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestIsolatedNewInvalid(t *testing.T) {
+	if _, err := NewIsolated(0); err == nil {
+		t.Errorf("NewIsolated(): Incorrectly created isolated engine with 0 workers")
+	}
+}
+
+func TestIsolatedMessage(t *testing.T) {
+	var buf bytes.Buffer
+
+	expected := &wireMessage{
+		Name:   "test",
+		Value:  map[string]interface{}{"a": []interface{}{int64(1), 2.5, "b"}},
+		Header: http.Header{"X-Testing": []string{"Hello"}},
+		Status: http.StatusCreated,
+		Error:  &Error{Level: LevelWarning, Message: "Test Warning", File: "test.php", Line: 1},
+	}
+
+	if err := writeMessage(&buf, msgResult, expected); err != nil {
+		t.Fatalf("writeMessage(): %s", err)
+	}
+
+	if buf.Bytes()[0] != msgResult {
+		t.Errorf("writeMessage(): Expected type '%d', actual '%d'", msgResult, buf.Bytes()[0])
+	}
+
+	typ, actual, err := readMessage(&buf)
+	if err != nil {
+		t.Fatalf("readMessage(): %s", err)
+	}
+
+	if typ != msgResult || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("readMessage(): Expected message '%#v', actual '%#v'", expected, actual)
+	}
+
+	if _, _, err := readMessage(&buf); err == nil {
+		t.Errorf("readMessage(): Expected error for empty input, got none")
+	}
+}
+
+func TestIsolatedEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
+
+func TestIsolatedNewWithConfig(t *testing.T) {
+	e, err := NewIsolatedWithConfig(Config{Ini: map[string]string{"precision": "5"}}, 1)
+	if err != nil {
+		t.Fatalf("NewIsolatedWithConfig(): %s", err)
+	}
+
+	defer e.Destroy()
+
+	c, err := e.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext(): %s", err)
+	}
+
+	defer c.Destroy()
+
+	// Worker processes are started with the configuration given.
+	val, err := c.Eval("return ini_get('precision');")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	if actual := val.String(); actual != "5" {
+		t.Errorf("NewIsolatedWithConfig(): Expected worker directive '5', actual '%s'", actual)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#include <errno.h>
+#include <stdbool.h>
+
+#include <main/php.h>
+#include <ext/standard/info.h>
+
+#include "value.h"
+#include "function.h"
+#include "engine.h"
+#include "module.h"
+#include "_cgo_export.h"
+
+// Modules defined for the engine, in order of definition.
+static engine_module **modules = NULL;
+static int module_count = 0;
+
+// Module entries passed to PHP on engine startup.
+static zend_module_entry *module_list = NULL;
+
+// Returns the module defined for the name given, if any.
+static engine_module *module_find_name(const char *name) {
+	int i;
+
+	for (i = 0; i < module_count; i++) {
+		if (strcmp(modules[i]->entry.name, name) == 0) {
+			return modules[i];
+		}
+	}
+
+	return NULL;
+}
+
+// Returns the module started with the module number given, if any.
+static engine_module *module_find_number(int number) {
+	int i;
+
+	for (i = 0; i < module_count; i++) {
+		if (modules[i]->number == number) {
+			return modules[i];
+		}
+	}
+
+	return NULL;
+}
+
+// Register module definitions on module startup (MINIT) and call Go startup
+// hook. The module being started is only known by its name at this point, and
+// is matched by module number for all subsequent calls. Startup errors are
+// handled by the engine once startup completes, as PHP would otherwise halt.
+static int module_startup(INIT_FUNC_ARGS) {
+	engine_module *module = module_find_name(EG(current_module)->name);
+	if (module == NULL) {
+		return FAILURE;
+	}
+
+	module->number = module_number;
+
+	if (module->ini_count > 0) {
+		_module_ini_register(module);
+	}
+
+	engineModuleStartup((char *) module->entry.name, module_number);
+
+	return SUCCESS;
+}
+
+// Unregister module definitions on module shutdown (MSHUTDOWN).
+static int module_shutdown(SHUTDOWN_FUNC_ARGS) {
+	engine_module *module = module_find_number(module_number);
+
+	if (module != NULL && module->ini_count > 0) {
+		_module_ini_unregister(module_number);
+	}
+
+	return SUCCESS;
+}
+
+// Call Go request startup hook for module (RINIT).
+static int module_request_startup(INIT_FUNC_ARGS) {
+	engine_module *module = module_find_number(module_number);
+
+	if (module != NULL) {
+		engineModuleRequestStartup((char *) module->entry.name);
+	}
+
+	return SUCCESS;
+}
+
+// Call Go request shutdown hook for module (RSHUTDOWN).
+static int module_request_shutdown(SHUTDOWN_FUNC_ARGS) {
+	engine_module *module = module_find_number(module_number);
+
+	if (module != NULL) {
+		engineModuleRequestShutdown((char *) module->entry.name);
+	}
+
+	return SUCCESS;
+}
+
+// Print module information, as shown by 'phpinfo()'.
+static void module_info(ZEND_MODULE_INFO_FUNC_ARGS) {
+	php_info_print_table_start();
+	php_info_print_table_row(2, "Version", zend_module->version);
+	php_info_print_table_end();
+
+	DISPLAY_INI_ENTRIES();
+}
+
+static zend_module_entry module_template = {
+	STANDARD_MODULE_HEADER,
+	NULL,                    // Name
+	NULL,                    // Functions
+	module_startup,          // Module Startup
+	module_shutdown,         // Module Shutdown
+	module_request_startup,  // Request Startup
+	module_request_shutdown, // Request Shutdown
+	module_info,             // Module Info
+	NULL,                    // Version
+	STANDARD_MODULE_PROPERTIES
+};
+
+// Create module with the name and version given. Modules are registered with
+// PHP on engine startup, and are retained until engine shutdown.
+engine_module *module_new(char *name, char *version) {
+	engine_module **list = realloc(modules, sizeof(engine_module *) * (module_count + 1));
+	if (list == NULL) {
+		errno = 1;
+		return NULL;
+	}
+
+	modules = list;
+
+	engine_module *module = malloc(sizeof(engine_module));
+	if (module == NULL) {
+		errno = 1;
+		return NULL;
+	}
+
+	memset(module, 0, sizeof(engine_module));
+
+	module->entry = module_template;
+	module->entry.name = strdup(name);
+	module->entry.version = strdup(version);
+	module->number = -1;
+
+	// The function list is terminated by an empty entry.
+	module->functions = calloc(1, sizeof(zend_function_entry));
+
+	modules[module_count++] = module;
+
+	errno = 0;
+	return module;
+}
+
+// Add function with the name given to module, dispatching calls to the Go
+// function registered under the same name.
+void module_function_add(engine_module *module, char *name) {
+	zend_function_entry *functions = realloc(module->functions, sizeof(zend_function_entry) * (module->function_count + 2));
+	if (functions == NULL) {
+		errno = 1;
+		return;
+	}
+
+	function_entry_set(&functions[module->function_count], name);
+	memset(&functions[module->function_count + 1], 0, sizeof(zend_function_entry));
+
+	module->functions = functions;
+	module->function_count++;
+
+	errno = 0;
+}
+
+// Add php.ini directive with the name and default value given to module. The
+// directive can be changed from anywhere, including scripts.
+void module_ini_add(engine_module *module, char *name, char *value) {
+	if (_module_ini_add(module, name, value) == FAILURE) {
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+// Register persistent constant with the name and scalar value given, for the
+// module number given.
+void module_constant_register(char *name, engine_value *value, int number) {
+	if (_module_constant_register(name, value->internal, number) == FAILURE) {
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+// Register persistent constant with the name and scalar value given, as defined
+// for the engine, if not already registered. Constants are registered for the
+// current thread only, as thread-safe builds of PHP keep separate constant
+// tables for each thread.
+void module_constant_define(char *name, engine_value *value) {
+	engine_thread_init();
+
+	if (_module_constant_exists(name)) {
+		errno = 1;
+		return;
+	}
+
+	// Engine constants are registered under the module number used for core
+	// constants, as they do not belong to any module.
+	module_constant_register(name, value, 0);
+}
+
+// Returns whether a constant is registered for the name given, on the current
+// thread.
+bool module_constant_exists(char *name) {
+	engine_thread_init();
+
+	return _module_constant_exists(name);
+}
+
+// Returns the list of entries for defined modules, as passed to PHP on engine
+// startup, and sets count to the number of entries.
+zend_module_entry *module_entries(unsigned int *count) {
+	int i;
+
+	*count = module_count;
+	if (module_count == 0) {
+		return NULL;
+	}
+
+	free(module_list);
+	module_list = malloc(sizeof(zend_module_entry) * module_count);
+
+	for (i = 0; i < module_count; i++) {
+		modules[i]->entry.functions = modules[i]->functions;
+		module_list[i] = modules[i]->entry;
+	}
+
+	return module_list;
+}
+
+// Free all defined modules, which are expected to have been shut down.
+void module_free_all(void) {
+	int i, j;
+
+	for (i = 0; i < module_count; i++) {
+		engine_module *module = modules[i];
+
+		for (j = 0; j < module->function_count; j++) {
+			free((char *) module->functions[j].fname);
+		}
+
+		_module_ini_free(module);
+
+		free((char *) module->entry.name);
+		free((char *) module->entry.version);
+		free(module->functions);
+		free(module);
+	}
+
+	free(modules);
+	free(module_list);
+
+	modules = NULL;
+	module_list = NULL;
+	module_count = 0;
+}
+
+#include "_module.c"
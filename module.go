@@ -0,0 +1,231 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+// #cgo CFLAGS: -I/usr/include/php -I/usr/include/php/main -I/usr/include/php/TSRM
+// #cgo CFLAGS: -I/usr/include/php/Zend -Iinclude
+//
+// #include <stdlib.h>
+// #include <main/php.h>
+// #include "value.h"
+// #include "module.h"
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// Module represents a PHP extension implemented in Go, registered with PHP on
+// engine startup alongside any built-in extensions. Modules are passed to the
+// engine in Config.Modules, and are visible to PHP scripts in the same way as
+// other extensions, e.g. via 'extension_loaded()' and 'phpversion()'.
+type Module struct {
+	// Startup, if set, is called once during engine startup (MINIT), after the
+	// module's constants and classes have been registered. An error returned
+	// causes engine initialization to fail.
+	Startup func() error
+
+	// RequestStartup and RequestShutdown, if set, are called at the start and
+	// end of every context created for the engine (RINIT and RSHUTDOWN), with
+	// the context being started or shut down.
+	RequestStartup  func(c *Context)
+	RequestShutdown func(c *Context)
+
+	name      string
+	version   string
+	functions []moduleFunction
+	classes   []moduleClass
+	constants []moduleConstant
+	ini       []moduleIni
+
+	// The error returned during module startup, if any.
+	err error
+}
+
+type moduleFunction struct {
+	name string
+	fn   reflect.Value
+}
+
+type moduleClass struct {
+	name string
+	fn   func(args []interface{}) interface{}
+}
+
+type moduleConstant struct {
+	name  string
+	value interface{}
+}
+
+type moduleIni struct {
+	name  string
+	value string
+}
+
+// NewModule returns a new, empty module for the name and version given. Module
+// names are expected to be unique among all extensions loaded by the engine.
+func NewModule(name, version string) *Module {
+	return &Module{name: name, version: version}
+}
+
+// DefineFunc adds a global PHP function for the name passed, which calls the Go
+// function fn whenever called from a PHP context. Arguments and results are
+// converted in the same way as for Engine.DefineFunc.
+func (m *Module) DefineFunc(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return fmt.Errorf("Failed to define function '%s' for non-function value of type '%T'", name, fn)
+	}
+
+	for _, f := range m.functions {
+		if strings.EqualFold(f.name, name) {
+			return fmt.Errorf("Failed to define duplicate function '%s'", name)
+		}
+	}
+
+	m.functions = append(m.functions, moduleFunction{name: name, fn: v})
+
+	return nil
+}
+
+// Define adds a PHP class for the name passed, using function fn as constructor
+// for individual object instances, in the same way as for Engine.Define.
+func (m *Module) Define(name string, fn func(args []interface{}) interface{}) error {
+	for _, c := range m.classes {
+		if c.name == name {
+			return fmt.Errorf("Failed to define duplicate receiver '%s'", name)
+		}
+	}
+
+	m.classes = append(m.classes, moduleClass{name: name, fn: fn})
+
+	return nil
+}
+
+// DefineConstant adds a persistent PHP constant for the name passed. Unlike
+// Engine.DefineConstant, values are limited to scalar values, as constants are
+// registered once on module startup.
+func (m *Module) DefineConstant(name string, val interface{}) error {
+	if err := checkScalarConstant(name, val); err != nil {
+		return err
+	}
+
+	for _, c := range m.constants {
+		if c.name == name {
+			return fmt.Errorf("Failed to define duplicate constant '%s'", name)
+		}
+	}
+
+	m.constants = append(m.constants, moduleConstant{name: name, value: val})
+
+	return nil
+}
+
+// DefineIni adds a php.ini directive for the name passed, with the default value
+// given. Directives can be set in the same way as for built-in directives, i.e.
+// in Config.Ini, in php.ini files, or at runtime, and their current values read
+// with 'ini_get()'.
+func (m *Module) DefineIni(name, value string) error {
+	if name == "" || strings.ContainsAny(name, "=[]\r\n") {
+		return fmt.Errorf("Invalid ini directive '%s'", name)
+	}
+
+	for _, i := range m.ini {
+		if i.name == name {
+			return fmt.Errorf("Failed to define duplicate ini directive '%s'", name)
+		}
+	}
+
+	m.ini = append(m.ini, moduleIni{name: name, value: value})
+
+	return nil
+}
+
+// DefineModule registers the module given with PHP, ahead of engine startup.
+func (e *Engine) defineModule(m *Module) error {
+	if m == nil || m.name == "" {
+		return fmt.Errorf("Failed to define module with empty name")
+	} else if _, exists := e.modules[m.name]; exists {
+		return fmt.Errorf("Failed to define duplicate module '%s'", m.name)
+	}
+
+	for _, f := range m.functions {
+		if _, exists := e.functions[strings.ToLower(f.name)]; exists {
+			return fmt.Errorf("Failed to define duplicate function '%s'", f.name)
+		}
+	}
+
+	name, version := C.CString(m.name), C.CString(m.version)
+	defer C.free(unsafe.Pointer(name))
+	defer C.free(unsafe.Pointer(version))
+
+	ptr, err := C.module_new(name, version)
+	if err != nil {
+		return fmt.Errorf("Failed to define module '%s'", m.name)
+	}
+
+	for _, f := range m.functions {
+		n := C.CString(f.name)
+		_, err := C.module_function_add(ptr, n)
+		C.free(unsafe.Pointer(n))
+
+		if err != nil {
+			return fmt.Errorf("Failed to define function '%s' for module '%s'", f.name, m.name)
+		}
+
+		e.functions[strings.ToLower(f.name)] = f.fn
+	}
+
+	for _, i := range m.ini {
+		n, v := C.CString(i.name), C.CString(i.value)
+		_, err := C.module_ini_add(ptr, n, v)
+		C.free(unsafe.Pointer(n))
+		C.free(unsafe.Pointer(v))
+
+		if err != nil {
+			return fmt.Errorf("Failed to define ini directive '%s' for module '%s'", i.name, m.name)
+		}
+	}
+
+	m.err = nil
+	e.modules[m.name] = m
+
+	return nil
+}
+
+// Startup registers constants and classes for the module, using the module
+// number assigned by PHP, and calls the module's startup hook, if any.
+func (m *Module) startup(e *Engine, number C.int) error {
+	for _, c := range m.constants {
+		v, err := NewValue(c.value)
+		if err != nil {
+			return err
+		}
+
+		n := C.CString(c.name)
+		_, err = C.module_constant_register(n, v.value, number)
+		C.free(unsafe.Pointer(n))
+		v.Destroy()
+
+		if err != nil {
+			return fmt.Errorf("Failed to define constant '%s' for module '%s'", c.name, m.name)
+		}
+	}
+
+	for _, c := range m.classes {
+		if err := e.Define(c.name, c.fn); err != nil {
+			return err
+		}
+	}
+
+	if m.Startup != nil {
+		return m.Startup()
+	}
+
+	return nil
+}
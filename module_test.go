@@ -0,0 +1,126 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var moduleCalls []string
+
+func TestModuleStart(t *testing.T) {
+	m := NewModule("gotest", "1.2.3")
+
+	m.DefineFunc("gotest_sum", func(a, b int) int { return a + b })
+	m.DefineConstant("GOTEST_NAME", "Hello")
+	m.DefineConstant("GOTEST_NUMBER", 42)
+	m.DefineIni("gotest.enabled", "1")
+	m.Define("GoTestObject", func(args []interface{}) interface{} { return &testReceiver{Var: "hello"} })
+
+	m.Startup = func() error {
+		moduleCalls = append(moduleCalls, "startup")
+		return nil
+	}
+
+	m.RequestStartup = func(c *Context) { moduleCalls = append(moduleCalls, "request_startup") }
+	m.RequestShutdown = func(c *Context) { moduleCalls = append(moduleCalls, "request_shutdown") }
+
+	var err error
+	if e, err = NewWithConfig(Config{Modules: []*Module{m}}); err != nil {
+		t.Fatalf("NewWithConfig(): %s", err)
+	}
+
+	t.SkipNow()
+}
+
+var moduleTests = []struct {
+	script   string
+	expected interface{}
+}{
+	{"return extension_loaded('gotest');", true},
+	{"return phpversion('gotest');", "1.2.3"},
+	{"return gotest_sum(1, 2);", int64(3)},
+	{"return GOTEST_NAME;", "Hello"},
+	{"return GOTEST_NUMBER;", int64(42)},
+	{"return ini_get('gotest.enabled');", "1"},
+	{"ini_set('gotest.enabled', '0'); return ini_get('gotest.enabled');", "0"},
+	{"$o = new GoTestObject(); return $o->Var;", "hello"},
+}
+
+func TestModuleEval(t *testing.T) {
+	for _, tt := range moduleTests {
+		c, _ := e.NewContext()
+
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			c.Destroy()
+			continue
+		}
+
+		if actual := val.Interface(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Context.Eval('%s'): Expected value '%#v', actual '%#v'", tt.script, tt.expected, actual)
+		}
+
+		c.Destroy()
+	}
+}
+
+func TestModuleHooks(t *testing.T) {
+	moduleCalls = []string{"startup"}
+
+	c, _ := e.NewContext()
+	c.Destroy()
+
+	expected := []string{"startup", "request_startup", "request_shutdown"}
+	if !reflect.DeepEqual(moduleCalls, expected) {
+		t.Errorf("Module hooks: Expected calls '%v', actual '%v'", expected, moduleCalls)
+	}
+}
+
+func TestModuleDefine(t *testing.T) {
+	m := NewModule("gotest_invalid", "1.0.0")
+
+	if err := m.DefineFunc("test", "invalid"); err == nil {
+		t.Errorf("Module.DefineFunc(): Incorrectly defined non-function value")
+	}
+
+	if err := m.DefineConstant("TEST", []string{"a"}); err == nil {
+		t.Errorf("Module.DefineConstant(): Incorrectly defined non-scalar value")
+	}
+
+	if err := m.DefineIni("test.a\ntest.b", "1"); err == nil {
+		t.Errorf("Module.DefineIni(): Incorrectly defined invalid directive")
+	}
+
+	m.DefineFunc("test", func() {})
+	if err := m.DefineFunc("TEST", func() {}); err == nil {
+		t.Errorf("Module.DefineFunc(): Incorrectly defined duplicate function")
+	}
+}
+
+func TestModuleEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
+
+func TestModuleStartupError(t *testing.T) {
+	m := NewModule("gotest", "1.0.0")
+	m.Startup = func() error { return fmt.Errorf("Test Error") }
+
+	if _, err := NewWithConfig(Config{Modules: []*Module{m}}); err == nil {
+		t.Fatalf("NewWithConfig(): Expected error for failed module startup, got none")
+	}
+
+	if engine != nil {
+		t.Errorf("NewWithConfig(): Engine remains active after failed module startup")
+	}
+
+	if _, err := NewWithConfig(Config{Modules: []*Module{m, m}}); err == nil {
+		t.Errorf("NewWithConfig(): Incorrectly created engine with duplicate modules")
+	}
+}
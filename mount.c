@@ -0,0 +1,158 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+#include <errno.h>
+#include <limits.h>
+#include <stdbool.h>
+#include <stdint.h>
+#include <sys/stat.h>
+
+#include <main/php.h>
+#include <main/php_streams.h>
+
+#include "mount.h"
+#include "_cgo_export.h"
+
+// Returns the path for the URL given, i.e. the part following the scheme, and
+// sets scheme to a copy of the scheme, which is to be freed by the caller.
+static const char *mount_url_parse(const char *url, char **scheme) {
+	const char *sep = strstr(url, "://");
+	if (sep == NULL) {
+		return NULL;
+	}
+
+	*scheme = strndup(url, sep - url);
+	return sep + 3;
+}
+
+// Open file for the URL given, and return a handle for the file, or 0 if the
+// file could not be opened. Mounted filesystems are read-only.
+static unsigned int mount_open(const char *url, const char *mode) {
+	char *scheme;
+	unsigned int handle;
+
+	if (mode[0] != 'r' || strchr(mode, '+') != NULL) {
+		return 0;
+	}
+
+	const char *path = mount_url_parse(url, &scheme);
+	if (path == NULL) {
+		return 0;
+	}
+
+	handle = engineMountOpen(scheme, (char *) path);
+	free(scheme);
+
+	return handle;
+}
+
+// Read up to count bytes from the file handle given into buf, and return the
+// number of bytes read, setting eof once no more data is available.
+static size_t mount_read(void *handle, char *buf, size_t count, bool *eof) {
+	if (count > INT_MAX) {
+		count = INT_MAX;
+	}
+
+	int read = engineMountRead((uintptr_t) handle, buf, count);
+	if (read <= 0) {
+		*eof = true;
+		return 0;
+	}
+
+	return read;
+}
+
+// Seek to the offset given for the file handle given, and return the resulting
+// offset, or -1 if the file does not support seeking.
+static long mount_seek(void *handle, long offset, int whence) {
+	return engineMountSeek((uintptr_t) handle, offset, whence);
+}
+
+static void mount_close(void *handle) {
+	engineMountClose((uintptr_t) handle);
+}
+
+// Fill stat buffer for a file of the kind given, where kind is 1 for regular
+// files and 2 for directories.
+static void mount_statbuf_set(php_stream_statbuf *ssb, int kind, long size, long mtime) {
+	memset(ssb, 0, sizeof(php_stream_statbuf));
+
+	ssb->sb.st_mode = (kind == 2) ? (S_IFDIR | 0555) : (S_IFREG | 0444);
+	ssb->sb.st_nlink = 1;
+	ssb->sb.st_size = size;
+	ssb->sb.st_mtime = mtime;
+}
+
+// Fill stat buffer for the URL given, and return 0 on success or -1 if the file
+// does not exist.
+static int mount_url_stat(const char *url, php_stream_statbuf *ssb) {
+	char *scheme;
+	long size, mtime;
+
+	const char *path = mount_url_parse(url, &scheme);
+	if (path == NULL) {
+		return -1;
+	}
+
+	int kind = engineMountStat(scheme, (char *) path, &size, &mtime);
+	free(scheme);
+
+	if (kind == 0) {
+		return -1;
+	}
+
+	mount_statbuf_set(ssb, kind, size, mtime);
+	return 0;
+}
+
+// Fill stat buffer for the file handle given, and return 0 on success or -1 on
+// failure.
+static int mount_stat(void *handle, php_stream_statbuf *ssb) {
+	long size, mtime;
+
+	int kind = engineMountFileStat((uintptr_t) handle, &size, &mtime);
+	if (kind == 0) {
+		return -1;
+	}
+
+	mount_statbuf_set(ssb, kind, size, mtime);
+	return 0;
+}
+
+// Returns the URL the filename given resolves to for the filesystem mounted for
+// includes, if any. The URL returned is to be freed by the caller.
+static char *mount_resolve(const char *filename) {
+	const char *executing = NULL;
+
+	if (zend_is_executing()) {
+		executing = zend_get_executed_filename();
+	}
+
+	return engineMountResolve((char *) filename, (char *) executing);
+}
+
+// Install handlers for resolving and opening included files, falling back to
+// handlers already in place for files not found in the filesystem mounted for
+// includes. Handlers are reset by PHP on every engine startup.
+void mount_init(void) {
+	_mount_init();
+}
+
+// Returns 1 if a stream wrapper is registered for the scheme given, 0 otherwise.
+int mount_exists(char *scheme) {
+	return _mount_exists(scheme);
+}
+
+// Register stream wrapper for mounted filesystem under the scheme given, for the
+// lifetime of the current context.
+void mount_register(char *scheme) {
+	if (_mount_register(scheme) == FAILURE) {
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+#include "_mount.c"
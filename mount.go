@@ -0,0 +1,248 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+// #cgo CFLAGS: -I/usr/include/php -I/usr/include/php/main -I/usr/include/php/TSRM
+// #cgo CFLAGS: -I/usr/include/php/Zend -Iinclude
+//
+// #include <stdlib.h>
+// #include <main/php.h>
+// #include "mount.h"
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Valid schemes for mounted filesystems, as accepted by PHP for stream wrappers.
+var mountScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]+$`)
+
+// MountFS represents a read-only filesystem mounted for access from PHP.
+type mountFS interface {
+	open(name string) (io.ReadCloser, error)
+	stat(name string) (os.FileInfo, error)
+}
+
+// Files opened by PHP from mounted filesystems, keyed by the handle passed to
+// PHP for each file. Handles are never zero.
+var mountFiles = struct {
+	sync.Mutex
+	files map[uint]io.ReadCloser
+	next  uint
+}{files: make(map[uint]io.ReadCloser)}
+
+// Mount registers the filesystem given as a PHP stream wrapper for the scheme
+// given, for all contexts subsequently created for the engine. If include is
+// true, the filesystem is also used for resolving relative paths for included
+// files.
+func (e *Engine) mount(scheme string, fsys mountFS, include bool) error {
+	if !mountScheme.MatchString(scheme) {
+		return fmt.Errorf("Failed to mount filesystem for invalid scheme '%s'", scheme)
+	}
+
+	n := C.CString(scheme)
+	defer C.free(unsafe.Pointer(n))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, exists := e.mounts[scheme]; exists || C.mount_exists(n) == 1 {
+		return fmt.Errorf("Failed to mount filesystem for duplicate scheme '%s'", scheme)
+	} else if include && e.includeMount != "" {
+		return fmt.Errorf("Failed to mount filesystem for includes, already mounted for scheme '%s'", e.includeMount)
+	}
+
+	e.mounts[scheme] = fsys
+	if include {
+		e.includeMount = scheme
+	}
+
+	return nil
+}
+
+// RegisterMounts registers stream wrappers for all filesystems mounted for the
+// engine, for the lifetime of the current context.
+func (e *Engine) registerMounts() error {
+	var schemes []string
+
+	e.mu.RLock()
+	for scheme := range e.mounts {
+		schemes = append(schemes, scheme)
+	}
+	e.mu.RUnlock()
+
+	for _, scheme := range schemes {
+		n := C.CString(scheme)
+		_, err := C.mount_register(n)
+		C.free(unsafe.Pointer(n))
+
+		if err != nil {
+			return fmt.Errorf("Failed to register stream wrapper for scheme '%s'", scheme)
+		}
+	}
+
+	return nil
+}
+
+// MountFS returns the filesystem mounted for the scheme given, if any.
+func (e *Engine) mountFS(scheme string) mountFS {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.mounts[scheme]
+}
+
+// MountResolve returns the URL for the file name given, as resolved against the
+// filesystem mounted for includes, if any; file names are resolved against the
+// root of the filesystem and, if executing is a file in the same filesystem,
+// against the directory containing it. An empty string is returned for file
+// names that are absolute, contain a scheme, or do not exist.
+func (e *Engine) mountResolve(name, executing string) string {
+	if e == nil || name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "://") {
+		return ""
+	}
+
+	e.mu.RLock()
+	scheme := e.includeMount
+	fsys := e.mounts[scheme]
+	e.mu.RUnlock()
+
+	if fsys == nil {
+		return ""
+	}
+
+	candidates := []string{mountPath(name)}
+
+	prefix := scheme + "://"
+	if strings.HasPrefix(executing, prefix) {
+		dir := path.Dir(mountPath(executing[len(prefix):]))
+		candidates = append(candidates, mountPath(path.Join(dir, name)))
+	}
+
+	for _, p := range candidates {
+		if info, err := fsys.stat(p); err == nil && !info.IsDir() {
+			return prefix + p
+		}
+	}
+
+	return ""
+}
+
+// MountPath returns the path within a mounted filesystem for the path given, as
+// taken from a URL.
+func mountPath(name string) string {
+	return path.Clean(strings.TrimLeft(name, "/"))
+}
+
+// MountOpen opens the file named in the filesystem given, and returns a handle
+// for the file, or 0 if the file could not be opened.
+func mountOpen(fsys mountFS, name string) uint {
+	if fsys == nil {
+		return 0
+	}
+
+	f, err := fsys.open(mountPath(name))
+	if err != nil {
+		return 0
+	}
+
+	mountFiles.Lock()
+	defer mountFiles.Unlock()
+
+	mountFiles.next++
+	if mountFiles.next == 0 {
+		mountFiles.next++
+	}
+
+	mountFiles.files[mountFiles.next] = f
+
+	return mountFiles.next
+}
+
+// MountFile returns the file for the handle given, if any.
+func mountFile(handle uint) io.ReadCloser {
+	mountFiles.Lock()
+	defer mountFiles.Unlock()
+
+	return mountFiles.files[handle]
+}
+
+// MountRead reads from the file for the handle given into buf, and returns the
+// number of bytes read, or 0 if no more data is available.
+func mountRead(handle uint, buf []byte) int {
+	f := mountFile(handle)
+	if f == nil {
+		return -1
+	}
+
+	for {
+		n, err := f.Read(buf)
+		if n > 0 || err != nil || len(buf) == 0 {
+			return n
+		}
+	}
+}
+
+// MountSeek seeks to the offset given for the file for the handle given, and
+// returns the resulting offset, or -1 if the file does not support seeking.
+func mountSeek(handle uint, offset int64, whence int) int64 {
+	s, ok := mountFile(handle).(io.Seeker)
+	if !ok {
+		return -1
+	}
+
+	n, err := s.Seek(offset, whence)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+// MountFileStat returns information for the file for the handle given.
+func mountFileStat(handle uint) (os.FileInfo, error) {
+	f, ok := mountFile(handle).(interface {
+		Stat() (os.FileInfo, error)
+	})
+
+	if !ok {
+		return nil, fmt.Errorf("Unable to stat mounted file")
+	}
+
+	return f.Stat()
+}
+
+// MountClose closes the file for the handle given.
+func mountClose(handle uint) {
+	mountFiles.Lock()
+	f := mountFiles.files[handle]
+	delete(mountFiles.files, handle)
+	mountFiles.Unlock()
+
+	if f != nil {
+		f.Close()
+	}
+}
+
+// MountStatKind returns the kind of file described by the information given, as
+// used by PHP: 1 for regular files and 2 for directories.
+func mountStatKind(info os.FileInfo) int {
+	if info.IsDir() {
+		return 2
+	}
+
+	return 1
+}
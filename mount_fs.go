@@ -0,0 +1,64 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+//
+// +build go1.16
+
+package php
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Mount registers fsys as a read-only PHP stream wrapper for the scheme given,
+// allowing files contained in fsys to be accessed from PHP using URLs of the form
+// 'scheme://path/to/file', e.g. in 'include' and 'require' statements, or in
+// calls to 'file_get_contents()', 'fopen()' and 'file_exists()'. Directories
+// can be checked for but not listed.
+//
+// Filesystems are available to all contexts subsequently created for the
+// engine. An error is returned if the scheme is invalid, or if a stream wrapper
+// is already registered for it.
+func (e *Engine) Mount(scheme string, fsys fs.FS) error {
+	return e.mount(scheme, &fsMount{fsys}, false)
+}
+
+// MountInclude mounts fsys in the same way as Mount, and additionally resolves
+// relative paths for scripts executed with Context.Exec, as well as for files
+// included from PHP, against fsys. Paths are resolved against the root of fsys,
+// and then against the directory of the including script, where that is also
+// contained in fsys. Files found in fsys take precedence over files found in the
+// include path, while files not found in fsys are resolved as usual. Included
+// files are identified by their URL, e.g. in '__FILE__'.
+//
+// Only a single filesystem may be mounted for includes.
+func (e *Engine) MountInclude(scheme string, fsys fs.FS) error {
+	return e.mount(scheme, &fsMount{fsys}, true)
+}
+
+// FsMount represents an fs.FS mounted for access from PHP.
+type fsMount struct {
+	fsys fs.FS
+}
+
+func (m *fsMount) open(name string) (io.ReadCloser, error) {
+	f, err := m.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Directories cannot be read from PHP.
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("Cannot open directory '%s'", name)
+	}
+
+	return f, nil
+}
+
+func (m *fsMount) stat(name string) (os.FileInfo, error) {
+	return fs.Stat(m.fsys, name)
+}
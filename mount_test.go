@@ -0,0 +1,113 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+//
+// +build go1.16
+
+package php
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMountStart(t *testing.T) {
+	e, _ = New()
+
+	err := e.Mount("assets", fstest.MapFS{
+		"hello.txt":    {Data: []byte("Hello World")},
+		"dir/file.txt": {Data: []byte("File")},
+	})
+
+	if err != nil {
+		t.Fatalf("Engine.Mount('assets'): %s", err)
+	}
+
+	err = e.MountInclude("app", fstest.MapFS{
+		"index.php":      {Data: []byte("<?php include 'lib/helper.php'; echo helper(), ' ', __FILE__;")},
+		"lib/helper.php": {Data: []byte("<?php require_once 'util.php'; function helper() { return util(); }")},
+		"lib/util.php":   {Data: []byte("<?php function util() { return 'Util'; }")},
+	})
+
+	if err != nil {
+		t.Fatalf("Engine.MountInclude('app'): %s", err)
+	}
+
+	t.SkipNow()
+}
+
+var mountTests = []struct {
+	script   string
+	expected interface{}
+}{
+	{"return file_get_contents('assets://hello.txt');", "Hello World"},
+	{"return file_get_contents('assets:///dir/file.txt');", "File"},
+	{"return [file_exists('assets://hello.txt'), file_exists('assets://missing.txt')];", []interface{}{true, false}},
+	{"return [is_file('assets://hello.txt'), is_dir('assets://dir'), filesize('assets://hello.txt')];", []interface{}{true, true, int64(11)}},
+	{"$f = fopen('assets://hello.txt', 'r'); fseek($f, 6); return fread($f, 5);", "World"},
+	{"return @fopen('assets://hello.txt', 'w');", false},
+	{"return @file_get_contents('assets://dir');", false},
+}
+
+func TestMountStream(t *testing.T) {
+	for _, tt := range mountTests {
+		c, _ := e.NewContext()
+
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			c.Destroy()
+			continue
+		}
+
+		if actual := val.Interface(); !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Context.Eval('%s'): Expected value '%#v', actual '%#v'", tt.script, tt.expected, actual)
+		}
+
+		c.Destroy()
+	}
+}
+
+func TestMountInclude(t *testing.T) {
+	var w bytes.Buffer
+
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	c.Output = &w
+
+	if err := c.Exec("index.php"); err != nil {
+		t.Fatalf("Context.Exec('index.php'): %s", err)
+	}
+
+	if expected := "Util app://index.php"; w.String() != expected {
+		t.Errorf("Context.Exec('index.php'): Expected output '%s', actual '%s'", expected, w.String())
+	}
+}
+
+func TestMountInvalid(t *testing.T) {
+	fsys := fstest.MapFS{}
+
+	if err := e.Mount("assets", fsys); err == nil {
+		t.Errorf("Engine.Mount(): Incorrectly mounted filesystem for duplicate scheme")
+	}
+
+	if err := e.Mount("file", fsys); err == nil {
+		t.Errorf("Engine.Mount(): Incorrectly mounted filesystem for built-in scheme")
+	}
+
+	if err := e.Mount("invalid scheme", fsys); err == nil {
+		t.Errorf("Engine.Mount(): Incorrectly mounted filesystem for invalid scheme")
+	}
+
+	if err := e.MountInclude("other", fsys); err == nil {
+		t.Errorf("Engine.MountInclude(): Incorrectly mounted second filesystem for includes")
+	}
+}
+
+func TestMountEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
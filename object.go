@@ -0,0 +1,178 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import "strings"
+
+// Visibility represents the visibility of a PHP object property.
+type Visibility int
+
+// Property visibilities, as declared in PHP classes. Dynamic properties are
+// always public.
+const (
+	Public Visibility = iota
+	Protected
+	Private
+)
+
+// Property represents a property of a PHP object.
+type Property struct {
+	// Name is the property name, as declared in PHP.
+	Name string
+
+	// Class is the name of the class declaring the property, for private
+	// properties, and is otherwise empty.
+	Class string
+
+	// Visibility is the property's visibility, as declared in PHP.
+	Visibility Visibility
+
+	// Value is the property value, as returned by Value.Interface.
+	Value interface{}
+}
+
+// ObjectValue represents a PHP object of any class other than stdClass, as
+// returned by Value.Interface. Objects of class stdClass are returned as maps of
+// their properties instead.
+//
+// An ObjectValue holds a handle to the live PHP object it was created from,
+// which can be used for calling methods on the object, and which is used in
+// place of the object's properties when the ObjectValue is converted back to a
+// PHP value. The handle is owned by the context the object was created in, and
+// is released along with the context when destroyed; it can be released before
+// that with Destroy, or with ReleaseObjects for objects contained in other
+// values.
+type ObjectValue struct {
+	// Class is the name of the object's class.
+	Class string
+
+	// Properties contains all properties set on the object, regardless of their
+	// visibility, in the order set on the object.
+	Properties []Property
+
+	value *Value
+}
+
+// NewObjectValue returns an ObjectValue for the PHP object value given. The
+// value is copied, and remains owned by the caller, while the copy is owned by
+// the current context, if any.
+func newObjectValue(v *Value) *ObjectValue {
+	o := &ObjectValue{Class: v.ClassName()}
+	o.value, _ = NewValue(v)
+
+	if c := currentContext(); c != nil && o.value != nil {
+		c.values = append(c.values, o.value)
+	}
+
+	keys := v.keys()
+	vals := v.Map()
+
+	for _, k := range keys {
+		p := Property{Name: k, Value: vals[k]}
+
+		// Names for non-public properties are mangled as "\0Class\0name" for
+		// private properties and "\0*\0name" for protected properties.
+		if strings.HasPrefix(k, "\x00") {
+			if n := strings.SplitN(k[1:], "\x00", 2); len(n) == 2 {
+				p.Name = n[1]
+				if n[0] == "*" {
+					p.Visibility = Protected
+				} else {
+					p.Class, p.Visibility = n[0], Private
+				}
+			}
+		}
+
+		o.Properties = append(o.Properties, p)
+	}
+
+	return o
+}
+
+// Value returns the handle to the live PHP object, or nil if the handle has
+// been released, or if the object was transferred from a worker process. The
+// Value returned is owned by the ObjectValue, and should not be destroyed
+// directly.
+func (o *ObjectValue) Value() *Value {
+	return o.handle()
+}
+
+// InstanceOf returns whether the object is an instance of the class or
+// interface named, as per Value.InstanceOf. False is returned if the object no
+// longer has a handle to the live PHP object.
+func (o *ObjectValue) InstanceOf(class string) bool {
+	if o.handle() == nil {
+		return false
+	}
+
+	return o.value.InstanceOf(class)
+}
+
+// Map returns the object's properties as a map of property names to values.
+// Properties sharing the same name, such as private properties declared in
+// parent classes, are set in the order set on the object.
+func (o *ObjectValue) Map() map[string]interface{} {
+	val := make(map[string]interface{}, len(o.Properties))
+	for _, p := range o.Properties {
+		val[p.Name] = p.Value
+	}
+
+	return val
+}
+
+// MarshalPHP returns the handle to the live PHP object, which is used in place
+// of the ObjectValue when converting to PHP values. Objects without a handle
+// are converted to associative arrays of their properties, as per Map.
+func (o *ObjectValue) MarshalPHP() (interface{}, error) {
+	if o.handle() == nil {
+		return o.Map(), nil
+	}
+
+	return o.value, nil
+}
+
+// Destroy releases the handle to the live PHP object, if any. Destroying an
+// object whose handle has already been released, either directly or by
+// destroying the context owning it, is a no-op.
+func (o *ObjectValue) Destroy() {
+	if o.value != nil {
+		o.value.Destroy()
+		o.value = nil
+	}
+}
+
+// Handle returns the handle to the live PHP object, or nil if the handle has
+// been released.
+func (o *ObjectValue) handle() *Value {
+	if o.value == nil || o.value.value == nil {
+		return nil
+	}
+
+	return o.value
+}
+
+// ReleaseObjects releases handles for all objects contained in the value given,
+// as returned by Value.Interface, including objects set as properties of other
+// objects. Values released remain usable after the context they were created in
+// has been destroyed, but objects contained in them can no longer be used for
+// calling methods, and are converted back to PHP values as arrays of their
+// properties.
+func ReleaseObjects(val interface{}) {
+	switch v := val.(type) {
+	case *ObjectValue:
+		v.Destroy()
+		for _, p := range v.Properties {
+			ReleaseObjects(p.Value)
+		}
+	case []interface{}:
+		for _, e := range v {
+			ReleaseObjects(e)
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			ReleaseObjects(e)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObjectStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+var objectScript = `class ObjectBase {
+	private $secret = 'hidden';
+	protected $shared = 'base';
+}
+
+class ObjectChild extends ObjectBase implements Countable {
+	public $name = 'child';
+	public function count() { return 42; }
+}
+
+$GLOBALS['original'] = new ObjectChild;
+return $GLOBALS['original'];`
+
+func TestObjectClass(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	val, err := c.Eval(objectScript)
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	defer val.Destroy()
+
+	if actual := val.ClassName(); actual != "ObjectChild" {
+		t.Errorf("Value.ClassName(): Expected 'ObjectChild', actual '%s'", actual)
+	}
+
+	for class, expected := range map[string]bool{"ObjectChild": true, "objectbase": true, "Countable": true, "stdClass": false, "Missing": false} {
+		if actual := val.InstanceOf(class); actual != expected {
+			t.Errorf("Value.InstanceOf('%s'): Expected '%t', actual '%t'", class, expected, actual)
+		}
+	}
+
+	str, _ := NewValue("ObjectChild")
+	defer str.Destroy()
+
+	if str.ClassName() != "" || str.InstanceOf("ObjectChild") {
+		t.Errorf("Value.ClassName(): Expected no class for non-object value")
+	}
+}
+
+func TestObjectInterface(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	val, err := c.Eval(objectScript)
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	defer val.Destroy()
+
+	obj, ok := val.Interface().(*ObjectValue)
+	if !ok {
+		t.Fatalf("Value.Interface(): Expected *ObjectValue, actual '%#v'", val.Interface())
+	}
+
+	defer obj.Destroy()
+
+	if obj.Class != "ObjectChild" || !obj.InstanceOf("ObjectBase") {
+		t.Errorf("Value.Interface(): Expected object of class 'ObjectChild', actual '%s'", obj.Class)
+	}
+
+	expected := map[string]Property{
+		"name":   {"name", "", Public, "child"},
+		"shared": {"shared", "", Protected, "base"},
+		"secret": {"secret", "ObjectBase", Private, "hidden"},
+	}
+
+	if len(obj.Properties) != len(expected) {
+		t.Errorf("Value.Interface(): Expected %d properties, actual %d", len(expected), len(obj.Properties))
+	}
+
+	for _, p := range obj.Properties {
+		if !reflect.DeepEqual(p, expected[p.Name]) {
+			t.Errorf("Value.Interface(): Expected property '%#v', actual '%#v'", expected[p.Name], p)
+		}
+	}
+
+	// The live object can be used for calling methods.
+	count, err := obj.Value().CallMethod("count")
+	if err != nil {
+		t.Fatalf("Value.CallMethod('count'): %s", err)
+	}
+
+	if count.Int() != 42 {
+		t.Errorf("Value.CallMethod('count'): Expected '42', actual '%d'", count.Int())
+	}
+
+	count.Destroy()
+
+	// Objects are bound back to PHP as the original object.
+	if err := c.Bind("bound", obj); err != nil {
+		t.Fatalf("Context.Bind(): %s", err)
+	}
+
+	same, err := c.Eval("return $bound === $GLOBALS['original'];")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	if !same.Bool() {
+		t.Errorf("Context.Bind(): Expected bound object to be identical to original object")
+	}
+
+	same.Destroy()
+}
+
+func TestObjectValueRelease(t *testing.T) {
+	c, _ := e.NewContext()
+
+	val, err := c.Eval("return [new ArrayObject, ['nested' => new ArrayIterator]];")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	list, ok := val.Interface().([]interface{})
+	if !ok || len(list) != 2 {
+		t.Fatalf("Value.Interface(): Expected list of objects, actual '%#v'", val.Interface())
+	}
+
+	obj := list[0].(*ObjectValue)
+	nested := list[1].(map[string]interface{})["nested"].(*ObjectValue)
+
+	// Handles released explicitly are no longer usable.
+	ReleaseObjects(list[1])
+	if nested.Value() != nil {
+		t.Errorf("ReleaseObjects(): Expected nested object handle to be released")
+	}
+
+	// Handles are owned by the context, and are released when it is destroyed.
+	c.Destroy()
+
+	if obj.Value() != nil || obj.InstanceOf("ArrayObject") {
+		t.Errorf("Context.Destroy(): Expected object handle to be released")
+	}
+
+	// Destroying objects after their context has been destroyed is a no-op.
+	obj.Destroy()
+	nested.Destroy()
+}
+
+var objectValueTests = []struct {
+	script   string
+	expected interface{}
+}{
+	{
+		"return (object) ['a' => 1];",
+		map[string]interface{}{"a": int64(1)},
+	},
+	{
+		"return [new ArrayObject, new ArrayIterator];",
+		[]interface{}{"ArrayObject", "ArrayIterator"},
+	},
+}
+
+func TestObjectValue(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	for _, tt := range objectValueTests {
+		val, err := c.Eval(tt.script)
+		if err != nil {
+			t.Errorf("Context.Eval('%s'): %s", tt.script, err)
+			continue
+		}
+
+		result := val.Interface()
+		actual := result
+
+		// Compare objects by class name only.
+		if s, ok := result.([]interface{}); ok {
+			classes := make([]interface{}, len(s))
+			for i := range s {
+				if o, ok := s[i].(*ObjectValue); ok {
+					classes[i] = o.Class
+				}
+			}
+
+			actual = classes
+		}
+
+		if reflect.DeepEqual(actual, tt.expected) == false {
+			t.Errorf("Value.Interface('%s'): expected '%#v', actual '%#v'", tt.script, tt.expected, actual)
+		}
+
+		ReleaseObjects(result)
+		val.Destroy()
+	}
+}
+
+func TestObjectEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
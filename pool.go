@@ -0,0 +1,440 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+)
+
+func init() {
+	// Register types used by PHP values for transfer to and from worker processes.
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+	gob.Register(&ObjectValue{})
+}
+
+// Job represents a unit of work executed by a Pool, in a new context created
+// for the job.
+type Job struct {
+	// Filename is the PHP script executed for the job, if any.
+	Filename string
+
+	// Script contains PHP code evaluated for the job, after Filename has been
+	// executed, if any. The value returned by the script is made available in
+	// the job result.
+	Script string
+
+	// Bindings contains values bound as PHP variables under the names given,
+	// prior to executing the job. Bindings follow the same rules as for
+	// Context.Bind. For process-based pools, bindings of types other than basic
+	// types, []interface{} and map[string]interface{} need to be registered with
+	// gob.Register.
+	Bindings map[string]interface{}
+}
+
+// Result represents the result of a Job executed by a Pool.
+type Result struct {
+	// Output contains any output produced during the job.
+	Output []byte
+
+	// Header and Status contain the HTTP headers and response status set during
+	// the job, as per Context.Header and Context.Status.
+	Header http.Header
+	Status int
+
+	// Value contains the value returned by the job's script, as returned by
+	// Value.Interface.
+	Value interface{}
+}
+
+// Pool executes jobs on a fixed number of workers, each of which owns the PHP
+// state it uses, allowing for jobs to be submitted concurrently from any
+// goroutine. For thread-safe (ZTS) builds of PHP, workers are goroutines locked
+// to dedicated OS threads, while for non thread-safe builds, workers are child
+// processes running the same executable.
+type Pool struct {
+	jobs    chan *poolJob
+	workers []poolWorker
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	// Set once the pool is closed, after which no jobs may be submitted.
+	closed bool
+	mu     sync.RWMutex
+}
+
+// NewPool creates a pool of size workers for the engine given. For non
+// thread-safe builds of PHP, worker processes are started by running the
+// program's executable, and the program is required to call ServeWorker on
+// startup, which creates the engine used by each worker process from the
+// configuration the engine given was created with. Engine definitions (such as
+// those made by Engine.Define or Engine.DefineFunc) are not passed on to worker
+// processes, and need to be made by the function passed to ServeWorker.
+func NewPool(e *Engine, size int) (*Pool, error) {
+	if e == nil || e.engine == nil {
+		return nil, fmt.Errorf("Cannot create pool for inactive engine")
+	} else if size < 1 {
+		return nil, fmt.Errorf("Cannot create pool of size %d", size)
+	}
+
+	if !threadSafe {
+		if err := checkWorker(); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &Pool{jobs: make(chan *poolJob)}
+
+	for i := 0; i < size; i++ {
+		var w poolWorker
+		if threadSafe {
+			w = &threadWorker{engine: e}
+		} else {
+			pw, err := newProcessWorker(newWorkerConfig(e.config))
+			if err != nil {
+				p.Close()
+				return nil, err
+			}
+
+			w = pw
+		}
+
+		p.workers = append(p.workers, w)
+		p.wg.Add(1)
+
+		go p.work(w)
+	}
+
+	return p, nil
+}
+
+// Run submits the job given for execution, and waits for and returns its
+// result. Errors raised during execution are returned in the same way as for
+// Context.Exec; the result returned is non-nil regardless, and contains any
+// output produced up until the error occurred. An error is returned if the pool
+// has been closed.
+func (p *Pool) Run(job *Job) (*Result, error) {
+	j := &poolJob{job: job, done: make(chan struct{})}
+
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return nil, fmt.Errorf("Pool is closed")
+	}
+
+	p.jobs <- j
+	p.mu.RUnlock()
+
+	<-j.done
+
+	return j.result, j.err
+}
+
+// Close stops all workers for the pool, waiting for any running jobs to
+// complete. Jobs submitted to the pool after it has been closed return an error.
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		close(p.jobs)
+		p.mu.Unlock()
+
+		p.wg.Wait()
+	})
+
+	return nil
+}
+
+func (p *Pool) work(w poolWorker) {
+	defer p.wg.Done()
+	defer w.close()
+
+	// Thread-based workers are bound to the same OS thread for their lifetime,
+	// as thread-local PHP state is reused between jobs.
+	if threadSafe {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+
+	for j := range p.jobs {
+		j.result, j.err = w.run(j.job)
+		close(j.done)
+	}
+}
+
+type poolJob struct {
+	job    *Job
+	result *Result
+	err    error
+	done   chan struct{}
+}
+
+// PoolWorker represents a worker executing jobs for a pool.
+type poolWorker interface {
+	run(job *Job) (*Result, error)
+	close() error
+}
+
+// ThreadWorker executes jobs in the calling thread, and is used for thread-safe
+// builds of PHP.
+type threadWorker struct {
+	engine *Engine
+}
+
+func (w *threadWorker) run(job *Job) (*Result, error) {
+	return runJob(w.engine, job)
+}
+
+func (w *threadWorker) close() error {
+	return nil
+}
+
+// ProcessWorker executes jobs in a child process, and is used for non
+// thread-safe builds of PHP. Worker processes that exit unexpectedly are
+// restarted when next needed.
+type processWorker struct {
+	config *workerConfig
+	cmd    *exec.Cmd
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	in     *os.File
+	out    *os.File
+}
+
+// PoolResponse represents the response for a job sent by a worker process.
+type poolResponse struct {
+	Result    *Result
+	Error     *Error
+	Exception *Exception
+	Message   string
+}
+
+func newProcessWorker(config *workerConfig) (*processWorker, error) {
+	w := &processWorker{config: config}
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Start starts a new worker process, connected to the pool over a pair of pipes,
+// and waits for the worker process to create its engine.
+func (w *processWorker) start() error {
+	name, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	}
+
+	jobsR, jobsW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	}
+
+	resultsR, resultsW, err := os.Pipe()
+	if err != nil {
+		jobsR.Close()
+		jobsW.Close()
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	}
+
+	cmd := exec.Command(name)
+	cmd.Env = append(os.Environ(), workerEnv+"="+poolWorkerKind)
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{jobsR, resultsW}
+
+	err = cmd.Start()
+
+	// Child ends of pipes are only used by the worker process.
+	jobsR.Close()
+	resultsW.Close()
+
+	if err != nil {
+		jobsW.Close()
+		resultsR.Close()
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	}
+
+	w.cmd = cmd
+	w.enc, w.dec = gob.NewEncoder(jobsW), gob.NewDecoder(resultsR)
+	w.in, w.out = jobsW, resultsR
+
+	// The worker process answers the configuration sent with an empty response
+	// once its engine has been created, or with the error that occurred.
+	var resp poolResponse
+	if err := w.enc.Encode(w.config); err != nil {
+		w.kill()
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	} else if err := w.dec.Decode(&resp); err != nil {
+		w.kill()
+		return fmt.Errorf("Unable to start pool worker: %s", err)
+	} else if resp.Message != "" {
+		w.kill()
+		return fmt.Errorf("Unable to start pool worker: %s", resp.Message)
+	}
+
+	return nil
+}
+
+func (w *processWorker) run(job *Job) (*Result, error) {
+	if w.cmd == nil {
+		if err := w.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The worker process is stopped on failure, as it may have exited or be left
+	// in an inconsistent state, and is restarted for the next job.
+	if err := w.enc.Encode(job); err != nil {
+		w.kill()
+		return nil, fmt.Errorf("Unable to send job to pool worker: %s", err)
+	}
+
+	var resp poolResponse
+	if err := w.dec.Decode(&resp); err != nil {
+		w.kill()
+		return nil, fmt.Errorf("Unable to receive result from pool worker: %s", err)
+	}
+
+	switch {
+	case resp.Error != nil:
+		return resp.Result, resp.Error
+	case resp.Exception != nil:
+		return resp.Result, resp.Exception
+	case resp.Message != "":
+		return resp.Result, fmt.Errorf("%s", resp.Message)
+	}
+
+	return resp.Result, nil
+}
+
+func (w *processWorker) close() error {
+	if w.cmd == nil {
+		return nil
+	}
+
+	// Closing the job pipe signals the worker process to exit.
+	w.in.Close()
+	err := w.cmd.Wait()
+	w.out.Close()
+
+	w.cmd = nil
+
+	return err
+}
+
+// Kill terminates the worker process immediately.
+func (w *processWorker) kill() {
+	w.cmd.Process.Kill()
+	w.close()
+}
+
+// ServePoolWorker creates the engine for the worker process from the
+// configuration received from the parent process, and executes jobs received
+// until no more jobs are available. The exit code for the worker process is
+// returned.
+func servePoolWorker(start func(config Config) (*Engine, error), in, out *os.File) int {
+	dec, enc := gob.NewDecoder(in), gob.NewEncoder(out)
+
+	var config workerConfig
+	if err := dec.Decode(&config); err != nil {
+		return 1
+	}
+
+	e, err := startWorker(start, &config)
+	if err != nil {
+		enc.Encode(&poolResponse{Message: err.Error()})
+		return 1
+	}
+
+	if err := enc.Encode(&poolResponse{}); err != nil {
+		e.Destroy()
+		return 1
+	}
+
+	for {
+		var job Job
+		if err := dec.Decode(&job); err != nil {
+			break
+		}
+
+		result, err := runJob(e, &job)
+		resp := &poolResponse{Result: result}
+
+		switch err := err.(type) {
+		case nil:
+		case *Error:
+			resp.Error = err
+		case *Exception:
+			resp.Exception = err
+		default:
+			resp.Message = err.Error()
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			e.Destroy()
+			return 1
+		}
+	}
+
+	e.Destroy()
+	return 0
+}
+
+// RunJob executes the job given in a new context for the engine.
+func runJob(e *Engine, job *Job) (*Result, error) {
+	var out bytes.Buffer
+
+	c, err := e.NewContext()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Output = &out
+	result := &Result{}
+
+	err = func() error {
+		for name, val := range job.Bindings {
+			if err := c.Bind(name, val); err != nil {
+				return err
+			}
+		}
+
+		if job.Filename != "" {
+			if err := c.Exec(job.Filename); err != nil {
+				return err
+			}
+		}
+
+		if job.Script != "" {
+			val, err := c.Eval(job.Script)
+			if err != nil {
+				return err
+			}
+
+			result.Value = val.Interface()
+			ReleaseObjects(result.Value)
+		}
+
+		return nil
+	}()
+
+	// Output may be produced until the context is destroyed, and the final status
+	// is only available then.
+	c.Destroy()
+
+	result.Output = out.Bytes()
+	result.Header = c.Header
+	result.Status = c.Status()
+
+	return result, err
+}
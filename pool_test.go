@@ -0,0 +1,218 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestPoolStart(t *testing.T) {
+	e, _ = New()
+	t.SkipNow()
+}
+
+var poolRunTests = []struct {
+	job      *Job
+	output   string
+	status   int
+	header   string
+	value    interface{}
+	hasError bool
+}{
+	{
+		&Job{Script: "echo 'Hello World';"},
+		"Hello World",
+		http.StatusOK,
+		"",
+		nil,
+		false,
+	},
+	{
+		&Job{Script: "header('X-Testing: Hello'); http_response_code(201); return $a + $b;", Bindings: map[string]interface{}{"a": 1, "b": 2}},
+		"",
+		http.StatusCreated,
+		"Hello",
+		int64(3),
+		false,
+	},
+	{
+		&Job{Script: "return ['a' => $s];", Bindings: map[string]interface{}{"s": "wow"}},
+		"",
+		http.StatusOK,
+		"",
+		map[string]interface{}{"a": "wow"},
+		false,
+	},
+	{
+		&Job{Script: "echo 'Before'; throw new Exception('Failed');"},
+		"Before",
+		http.StatusOK,
+		"",
+		nil,
+		true,
+	},
+}
+
+func TestPoolRun(t *testing.T) {
+	p, err := NewPool(e, 2)
+	if err != nil {
+		t.Fatalf("NewPool(): %s", err)
+	}
+
+	defer p.Close()
+
+	for _, tt := range poolRunTests {
+		result, err := p.Run(tt.job)
+		if tt.hasError != (err != nil) {
+			t.Errorf("Pool.Run('%s'): Expected error '%v', actual '%v'", tt.job.Script, tt.hasError, err)
+		}
+
+		if result == nil {
+			t.Errorf("Pool.Run('%s'): Expected result, got none", tt.job.Script)
+			continue
+		}
+
+		if string(result.Output) != tt.output {
+			t.Errorf("Pool.Run('%s'): Expected output '%s', actual '%s'", tt.job.Script, tt.output, result.Output)
+		}
+
+		if result.Status != tt.status {
+			t.Errorf("Pool.Run('%s'): Expected status '%d', actual '%d'", tt.job.Script, tt.status, result.Status)
+		}
+
+		if actual := result.Header.Get("X-Testing"); actual != tt.header {
+			t.Errorf("Pool.Run('%s'): Expected header '%s', actual '%s'", tt.job.Script, tt.header, actual)
+		}
+
+		if !reflect.DeepEqual(result.Value, tt.value) {
+			t.Errorf("Pool.Run('%s'): Expected value '%#v', actual '%#v'", tt.job.Script, tt.value, result.Value)
+		}
+	}
+}
+
+func TestPoolConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	p, err := NewPool(e, 4)
+	if err != nil {
+		t.Fatalf("NewPool(): %s", err)
+	}
+
+	defer p.Close()
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			result, err := p.Run(&Job{Script: "echo $i; return $i * 2;", Bindings: map[string]interface{}{"i": i}})
+			if err != nil {
+				t.Errorf("Pool.Run(%d): %s", i, err)
+				return
+			}
+
+			if string(result.Output) != fmt.Sprintf("%d", i) || result.Value != int64(i*2) {
+				t.Errorf("Pool.Run(%d): Unexpected result '%#v'", i, result)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestPoolRestart(t *testing.T) {
+	if ThreadSafe() {
+		t.Skip("Restarting workers requires process-based pool")
+	}
+
+	// Exiting within a job terminates the worker process, which is expected to
+	// be restarted for subsequent jobs. The function called is defined for
+	// worker processes in TestMain.
+	p, err := NewPool(e, 1)
+	if err != nil {
+		t.Fatalf("NewPool(): %s", err)
+	}
+
+	defer p.Close()
+
+	if _, err := p.Run(&Job{Script: "pool_crash();"}); err == nil {
+		t.Errorf("Pool.Run('pool_crash();'): Expected error for exited worker, got none")
+	}
+
+	result, err := p.Run(&Job{Script: "return 42;"})
+	if err != nil {
+		t.Fatalf("Pool.Run('return 42;'): %s", err)
+	}
+
+	if result.Value != int64(42) {
+		t.Errorf("Pool.Run('return 42;'): Expected value '42', actual '%#v'", result.Value)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, err := NewPool(e, 1)
+	if err != nil {
+		t.Fatalf("NewPool(): %s", err)
+	}
+
+	p.Close()
+
+	if _, err := p.Run(&Job{Script: "return 1;"}); err == nil {
+		t.Errorf("Pool.Run(): Incorrectly ran job on closed pool")
+	}
+
+	// Attempting to close a pool twice should be a no-op.
+	p.Close()
+}
+
+func TestPoolNewInvalid(t *testing.T) {
+	if _, err := NewPool(e, 0); err == nil {
+		t.Errorf("NewPool(): Incorrectly created pool of size 0")
+	}
+
+	if _, err := NewPool(nil, 1); err == nil {
+		t.Errorf("NewPool(): Incorrectly created pool for inactive engine")
+	}
+}
+
+func TestPoolWorkerConfig(t *testing.T) {
+	if ThreadSafe() {
+		t.Skip("Worker configuration requires process-based pool")
+	}
+
+	e.Destroy()
+
+	// Worker processes are started with the configuration of the engine given.
+	config := Config{Ini: map[string]string{"precision": "5"}}
+	if e, _ = NewWithConfig(config); e == nil {
+		t.Fatalf("NewWithConfig(): Failed to create engine")
+	}
+
+	p, err := NewPool(e, 1)
+	if err != nil {
+		t.Fatalf("NewPool(): %s", err)
+	}
+
+	defer p.Close()
+
+	result, err := p.Run(&Job{Script: "return ini_get('precision');"})
+	if err != nil {
+		t.Fatalf("Pool.Run(): %s", err)
+	}
+
+	if result.Value != "5" {
+		t.Errorf("Pool.Run(): Expected worker directive '5', actual '%#v'", result.Value)
+	}
+}
+
+func TestPoolEnd(t *testing.T) {
+	e.Destroy()
+	t.SkipNow()
+}
@@ -10,6 +10,7 @@
 #include <ext/standard/php_string.h>
 
 #include "value.h"
+#include "engine.h"
 #include "receiver.h"
 #include "_cgo_export.h"
 
@@ -159,6 +160,8 @@ static zend_object_handlers receiver_handlers = {
 
 // Define class with unique name.
 void receiver_define(char *name) {
+	engine_thread_init();
+
 	zend_class_entry tmp;
 	INIT_CLASS_ENTRY_EX(tmp, name, strlen(name), NULL);
 
@@ -171,9 +174,45 @@ void receiver_define(char *name) {
 	_receiver_handlers_set(&receiver_handlers);
 }
 
+// Define class with the name given for the current thread, if not already
+// defined. Thread-safe builds of PHP keep separate class tables for each thread,
+// which only contain classes defined during module startup.
+void receiver_define_thread(char *name) {
+	#ifdef ZTS
+		size_t len = strlen(name);
+		char *lcname = zend_str_tolower_dup(name, len);
+
+		if (!zend_hash_str_exists(CG(class_table), lcname, len)) {
+			receiver_define(name);
+		}
+
+		efree(lcname);
+	#endif
+}
+
 void receiver_destroy(char *name) {
+	engine_thread_init();
+
 	name = php_strtolower(name, strlen(name));
 	_receiver_destroy(name);
 }
 
+// Returns the method receiver for value, or NULL if the value is not an object
+// instance of a method receiver class.
+engine_receiver *receiver_from_value(engine_value *val) {
+	if (Z_TYPE_P(val->internal) != IS_OBJECT || Z_OBJ_HT_P(val->internal) != &receiver_handlers) {
+		return NULL;
+	}
+
+	return _receiver_this(val->internal);
+}
+
+// Set value to the PHP object for the method receiver given.
+void receiver_value_set(engine_receiver *rcvr, engine_value *val) {
+	zval object;
+
+	_receiver_object(rcvr, &object);
+	value_set_zval(val, &object);
+}
+
 #include "_receiver.c"
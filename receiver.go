@@ -8,21 +8,26 @@ package php
 // #cgo CFLAGS: -I/usr/include/php/Zend -Iinclude
 //
 // #include <stdlib.h>
+// #include <stdbool.h>
 // #include <main/php.h>
+// #include "value.h"
 // #include "receiver.h"
 import "C"
 
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"unsafe"
 )
 
 // Receiver represents a method receiver.
 type Receiver struct {
-	name    string
-	create  func(args []interface{}) interface{}
-	objects map[*C.struct__engine_receiver]*ReceiverObject
+	name      string
+	create    func(args []interface{}) interface{}
+	objects   map[*C.struct__engine_receiver]*ReceiverObject
+	instances map[interface{}]*C.struct__engine_receiver
+	mu        sync.RWMutex
 }
 
 // NewObject instantiates a new method receiver object, using the Receiver's
@@ -76,7 +81,71 @@ func (r *Receiver) Destroy() {
 
 	C.receiver_destroy(n)
 	r.create = nil
+
+	r.mu.Lock()
 	r.objects = nil
+	r.instances = nil
+	r.mu.Unlock()
+}
+
+// Object returns the receiver object attached to the PHP object given, if any.
+func (r *Receiver) object(rcvr *C.struct__engine_receiver) *ReceiverObject {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.objects[rcvr]
+}
+
+// SetObject attaches the receiver object given to a PHP object, created in the
+// context given. Receiver instances of pointer type are also tracked, so that
+// they can be converted back to the same PHP object.
+func (r *Receiver) setObject(rcvr *C.struct__engine_receiver, obj *ReceiverObject, c *Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.objects == nil {
+		return
+	}
+
+	obj.context = c
+	r.objects[rcvr] = obj
+
+	if reflect.ValueOf(obj.instance).Kind() == reflect.Ptr {
+		r.instances[obj.instance] = rcvr
+	}
+}
+
+// DeleteObject detaches the receiver object attached to the PHP object given,
+// if any.
+func (r *Receiver) deleteObject(rcvr *C.struct__engine_receiver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	obj, exists := r.objects[rcvr]
+	if !exists {
+		return
+	}
+
+	delete(r.objects, rcvr)
+
+	if r.instances[obj.instance] == rcvr {
+		delete(r.instances, obj.instance)
+	}
+}
+
+// Instance returns the PHP object attached to the receiver instance given, if
+// the object was created in the context given. The instance must be of pointer
+// type.
+func (r *Receiver) instance(instance interface{}, c *Context) *C.struct__engine_receiver {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rcvr, exists := r.instances[instance]
+	if obj := r.objects[rcvr]; !exists || obj == nil || obj.context != c {
+		return nil
+	}
+
+	return rcvr
 }
 
 // ReceiverObject represents an object instance of a pre-defined method receiver.
@@ -84,6 +153,7 @@ type ReceiverObject struct {
 	instance interface{}
 	values   map[string]reflect.Value
 	methods  map[string]reflect.Value
+	context  *Context
 }
 
 // Get returns a named internal property of the receiver object instance, or an
@@ -101,15 +171,22 @@ func (o *ReceiverObject) Get(name string) (*Value, error) {
 	return val, nil
 }
 
-// Set assigns value to named internal property. If the named property does not
-// exist or cannot be set, the method does nothing.
+// Set assigns value to named internal property, converting the value to the
+// property's type as for function arguments. If the named property does not
+// exist, cannot be set, or the value cannot be converted, the method does
+// nothing.
 func (o *ReceiverObject) Set(name string, val interface{}) {
 	// Do not attempt to set non-existing or unset-able field.
 	if _, exists := o.values[name]; !exists || !o.values[name].CanSet() {
 		return
 	}
 
-	o.values[name].Set(reflect.ValueOf(val))
+	v, err := convertArg(val, o.values[name].Type())
+	if err != nil {
+		return
+	}
+
+	o.values[name].Set(v)
 }
 
 // Exists checks if named internal property exists and returns true, or false if
@@ -161,3 +238,42 @@ func (o *ReceiverObject) Call(name string, args []interface{}) *Value {
 
 	return v
 }
+
+// ReceiverInstance returns the method receiver instance attached to the internal
+// PHP object, if the object is an instance of a method receiver class.
+func (v *Value) receiverInstance() interface{} {
+	rcvr := C.receiver_from_value(v.value)
+	if rcvr == nil {
+		return nil
+	}
+
+	obj := engine.receiverObject(rcvr)
+	if obj == nil {
+		return nil
+	}
+
+	return obj.instance
+}
+
+// NewReceiverValue returns a Value for the PHP object attached to the method
+// receiver instance given, if the object was created in the current context.
+// Instances not attached to any PHP object return nil.
+func newReceiverValue(instance interface{}) *Value {
+	if reflect.ValueOf(instance).Kind() != reflect.Ptr {
+		return nil
+	}
+
+	rcvr := engine.receiverInstance(instance, currentContext())
+	if rcvr == nil {
+		return nil
+	}
+
+	ptr, err := C.value_new()
+	if err != nil {
+		return nil
+	}
+
+	C.receiver_value_set(rcvr, ptr)
+
+	return &Value{value: ptr}
+}
@@ -30,6 +30,14 @@ func (t *testReceiver) Goodbye(p string) (string, string) {
 	return "Goodbye", p
 }
 
+func (t *testReceiver) Greet(o *testReceiver) string {
+	return t.Var + " greets " + o.Var
+}
+
+func (t *testReceiver) Self() *testReceiver {
+	return t
+}
+
 func (t *testReceiver) invalid() string {
 	return "I'm afraid I can't let you do that, Dave"
 }
@@ -117,6 +125,14 @@ var receiverDefineTests = []struct {
 		"$t = new TestReceiver; echo isset($t->hidden) ? 1 : 0;",
 		"0",
 	},
+	{
+		"$a = new TestReceiver('A'); $b = new TestReceiver('B'); echo $a->Greet($b);",
+		"A greets B",
+	},
+	{
+		"$t = new TestReceiver; echo ($t->Self() === $t) ? 1 : 0;",
+		"1",
+	},
 }
 
 func TestReceiverDefine(t *testing.T) {
@@ -152,6 +168,69 @@ func TestReceiverDefine(t *testing.T) {
 	c.Destroy()
 }
 
+func TestReceiverInstance(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	val, err := c.Eval("$GLOBALS['original'] = new TestReceiver('Instance'); return $GLOBALS['original'];")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	defer val.Destroy()
+
+	rcvr, ok := val.Interface().(*testReceiver)
+	if !ok || rcvr.Var != "Instance" {
+		t.Fatalf("Value.Interface(): Expected method receiver instance, actual '%#v'", val.Interface())
+	}
+
+	// Binding the instance back to PHP should return the original object.
+	if err := c.Bind("bound", rcvr); err != nil {
+		t.Fatalf("Context.Bind(): %s", err)
+	}
+
+	same, err := c.Eval("return $bound === $GLOBALS['original'];")
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	defer same.Destroy()
+
+	if !same.Bool() {
+		t.Errorf("Context.Bind(): Expected bound instance to be identical to original object")
+	}
+}
+
+func TestReceiverNew(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	val, err := c.New("TestReceiver", "New")
+	if err != nil {
+		t.Fatalf("Context.New(): %s", err)
+	}
+
+	rcvr, ok := val.Interface().(*testReceiver)
+	if !ok || rcvr.Var != "New" {
+		t.Fatalf("Context.New(): Expected method receiver instance, actual '%#v'", val.Interface())
+	}
+
+	result, err := val.CallMethod("Hello", "World")
+	if err != nil {
+		t.Fatalf("Value.CallMethod(): %s", err)
+	}
+
+	defer result.Destroy()
+
+	if actual := result.String(); actual != "Hello World" {
+		t.Errorf("Value.CallMethod(): Expected result 'Hello World', actual '%s'", actual)
+	}
+
+	if _, err := c.New("TestReceiver", false); err == nil {
+		t.Errorf("Context.New(): Incorrectly created receiver instance for failing constructor")
+	}
+}
+
 func TestReceiverDestroy(t *testing.T) {
 	c, _ := e.NewContext()
 	defer c.Destroy()
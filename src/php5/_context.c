@@ -6,6 +6,10 @@ static void _context_bind(char *name, zval *value) {
 	ZEND_SET_SYMBOL(EG(active_symbol_table), name, value);
 }
 
+static void _context_set_opened_path(zend_file_handle *handle, char *name) {
+	handle->opened_path = estrdup(name);
+}
+
 static void _context_eval(zend_op_array *op, zval *ret) {
 	zend_op_array *oparr = EG(active_op_array);
 	zval *retval = NULL;
@@ -48,3 +52,7 @@ static void _context_eval(zend_op_array *op, zval *ret) {
 	EG(active_op_array) = oparr;
 	EG(return_value_ptr_ptr) = retvalptr;
 }
+
+static int _context_set_ini(char *name, char *value) {
+	return zend_alter_ini_entry(name, strlen(name) + 1, value, strlen(value), PHP_INI_USER | PHP_INI_PERDIR, PHP_INI_STAGE_RUNTIME);
+}
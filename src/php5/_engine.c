@@ -5,3 +5,34 @@
 static int _engine_ub_write(const char *str, uint len) {
 	return engine_ub_write(str, len);
 }
+
+static int _engine_read_post(char *buffer, uint count_bytes) {
+	return engine_read_post(buffer, count_bytes);
+}
+
+static void _engine_extension_add(zval *list, const char *name, const char *version, bool zend) {
+	zval *entry;
+
+	MAKE_STD_ZVAL(entry);
+	array_init_size(entry, 3);
+
+	add_next_index_string(entry, name, 1);
+	add_next_index_string(entry, (version != NULL) ? version : "", 1);
+	add_next_index_bool(entry, zend);
+
+	add_next_index_zval(list, entry);
+}
+
+static int _engine_module_add(zend_module_entry *module, zval *list) {
+	_engine_extension_add(list, module->name, module->version, false);
+	return ZEND_HASH_APPLY_KEEP;
+}
+
+static void _engine_zend_extension_add(zend_extension *ext, zval *list) {
+	_engine_extension_add(list, ext->name, ext->version, true);
+}
+
+static void _engine_extensions(zval *list) {
+	zend_hash_apply_with_argument(&module_registry, (apply_func_arg_t) _engine_module_add, list);
+	zend_llist_apply_with_argument(&zend_extensions, (llist_apply_with_arg_func_t) _engine_zend_extension_add, list);
+}
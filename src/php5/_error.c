@@ -0,0 +1,56 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+static void _error_handler_init(zend_internal_function *func, void (*handler)(INTERNAL_FUNCTION_PARAMETERS)) {
+	memset(func, 0, sizeof(zend_internal_function));
+
+	func->type          = ZEND_INTERNAL_FUNCTION;
+	func->handler       = handler;
+	func->function_name = "gophp_exception_handler";
+}
+
+static void _error_handler_set(zend_internal_function *func) {
+	MAKE_STD_ZVAL(EG(user_exception_handler));
+	zend_create_closure(EG(user_exception_handler), (zend_function *) func, NULL, NULL);
+}
+
+static void _error_handler_destroy(zend_internal_function *func) {
+	// Do nothing.
+}
+
+static void _error_exception(engine_context *context, zval *ex) {
+	zend_class_entry *ce = Z_OBJCE_P(ex);
+	zend_class_entry *base = zend_exception_get_default();
+	zval *trace = NULL;
+
+	zval *message = zend_read_property(base, ex, "message", sizeof("message") - 1, 1);
+	zval *file = zend_read_property(base, ex, "file", sizeof("file") - 1, 1);
+	zval *line = zend_read_property(base, ex, "line", sizeof("line") - 1, 1);
+	zval *code = zend_read_property(base, ex, "code", sizeof("code") - 1, 1);
+
+	zend_call_method_with_0_params(&ex, ce, NULL, "gettraceasstring", &trace);
+
+	engineSetException(context, (char *) ce->name,
+		(Z_TYPE_P(message) == IS_STRING) ? Z_STRVAL_P(message) : "",
+		(Z_TYPE_P(code) == IS_LONG) ? Z_LVAL_P(code) : 0,
+		(Z_TYPE_P(file) == IS_STRING) ? Z_STRVAL_P(file) : "",
+		(Z_TYPE_P(line) == IS_LONG) ? Z_LVAL_P(line) : 0,
+		(trace != NULL && Z_TYPE_P(trace) == IS_STRING) ? Z_STRVAL_P(trace) : "");
+
+	if (trace != NULL) {
+		zval_ptr_dtor(&trace);
+	}
+}
+
+static void _error_exception_clear(void) {
+	zval *ex = EG(exception);
+
+	// Keep a reference to the exception, as clearing it would otherwise destroy it.
+	Z_ADDREF_P(ex);
+
+	zend_clear_exception();
+
+	error_exception(ex);
+	zval_ptr_dtor(&ex);
+}
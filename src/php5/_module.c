@@ -0,0 +1,88 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+static int _module_ini_add(engine_module *module, char *name, char *value) {
+	zend_ini_entry *entries = realloc(module->ini_entries, sizeof(zend_ini_entry) * (module->ini_count + 2));
+	if (entries == NULL) {
+		return FAILURE;
+	}
+
+	// The list of entries is terminated by an empty entry.
+	zend_ini_entry *entry = &entries[module->ini_count];
+	memset(entry, 0, sizeof(zend_ini_entry) * 2);
+
+	entry->name = strdup(name);
+	entry->name_length = strlen(name) + 1;
+	entry->value = strdup(value);
+	entry->value_length = strlen(value);
+	entry->modifiable = PHP_INI_ALL;
+
+	module->ini_entries = entries;
+	module->ini_count++;
+
+	return SUCCESS;
+}
+
+static void _module_ini_register(engine_module *module) {
+	zend_register_ini_entries((zend_ini_entry *) module->ini_entries, module->number);
+}
+
+static void _module_ini_unregister(int number) {
+	zend_unregister_ini_entries(number);
+}
+
+static void _module_ini_free(engine_module *module) {
+	zend_ini_entry *entries = (zend_ini_entry *) module->ini_entries;
+	int i;
+
+	for (i = 0; i < module->ini_count; i++) {
+		free(entries[i].name);
+		free(entries[i].value);
+	}
+
+	free(entries);
+}
+
+static int _module_constant_register(char *name, zval *value, int number) {
+	zend_constant c;
+
+	switch (Z_TYPE_P(value)) {
+	case IS_NULL:
+	case IS_BOOL:
+	case IS_LONG:
+	case IS_DOUBLE:
+		c.value = *value;
+		break;
+	case IS_STRING:
+		// Values for persistent constants are expected to be allocated persistently.
+		ZVAL_STRINGL(&c.value, zend_strndup(Z_STRVAL_P(value), Z_STRLEN_P(value)), Z_STRLEN_P(value), 0);
+		break;
+	default:
+		return FAILURE;
+	}
+
+	c.flags = CONST_CS | CONST_PERSISTENT;
+	c.name = zend_strndup(name, strlen(name));
+	c.name_len = strlen(name) + 1;
+	c.module_number = number;
+
+	return zend_register_constant(&c);
+}
+
+static int _module_constant_exists(char *name) {
+	size_t len = strlen(name);
+	char *slash = strrchr(name, '\\');
+	int exists;
+
+	// Namespaces for case-sensitive constants are stored in lower-case.
+	char *key = estrndup(name, len);
+	if (slash != NULL) {
+		zend_str_tolower(key, slash - name);
+	}
+
+	exists = zend_hash_exists(EG(zend_constants), key, len + 1);
+	efree(key);
+
+	return exists;
+}
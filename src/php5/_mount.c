@@ -0,0 +1,141 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+static size_t _mount_stream_write(php_stream *stream, const char *buf, size_t count TSRMLS_DC) {
+	return 0;
+}
+
+static size_t _mount_stream_read(php_stream *stream, char *buf, size_t count TSRMLS_DC) {
+	bool eof = false;
+	size_t read = mount_read(stream->abstract, buf, count, &eof);
+
+	if (eof) {
+		stream->eof = 1;
+	}
+
+	return read;
+}
+
+static int _mount_stream_close(php_stream *stream, int close_handle TSRMLS_DC) {
+	mount_close(stream->abstract);
+	return 0;
+}
+
+static int _mount_stream_seek(php_stream *stream, off_t offset, int whence, off_t *newoffset TSRMLS_DC) {
+	long result = mount_seek(stream->abstract, offset, whence);
+	if (result < 0) {
+		return -1;
+	}
+
+	*newoffset = result;
+	return 0;
+}
+
+static int _mount_stream_stat(php_stream *stream, php_stream_statbuf *ssb TSRMLS_DC) {
+	return mount_stat(stream->abstract, ssb);
+}
+
+static php_stream_ops _mount_stream_ops = {
+	_mount_stream_write, // Write
+	_mount_stream_read,  // Read
+	_mount_stream_close, // Close
+	NULL,                // Flush
+	"gophp-mount",       // Label
+	_mount_stream_seek,  // Seek
+	NULL,                // Cast
+	_mount_stream_stat,  // Stat
+	NULL                 // Set Option
+};
+
+static php_stream *_mount_wrapper_open(php_stream_wrapper *wrapper, const char *filename, const char *mode, int options, char **opened_path, php_stream_context *context STREAMS_DC TSRMLS_DC) {
+	unsigned int handle = mount_open(filename, mode);
+	if (handle == 0) {
+		return NULL;
+	}
+
+	php_stream *stream = php_stream_alloc(&_mount_stream_ops, (void *) (uintptr_t) handle, 0, mode);
+	if (stream == NULL) {
+		mount_close((void *) (uintptr_t) handle);
+		return NULL;
+	}
+
+	if (opened_path != NULL) {
+		*opened_path = estrdup(filename);
+	}
+
+	return stream;
+}
+
+static int _mount_wrapper_stat(php_stream_wrapper *wrapper, const char *url, int flags, php_stream_statbuf *ssb, php_stream_context *context TSRMLS_DC) {
+	return mount_url_stat(url, ssb);
+}
+
+static php_stream_wrapper_ops _mount_wrapper_ops = {
+	_mount_wrapper_open, // Open
+	NULL,                // Close
+	NULL,                // Stat
+	_mount_wrapper_stat, // URL Stat
+	NULL,                // Open Directory
+	"gophp-mount",       // Label
+	NULL,                // Unlink
+	NULL,                // Rename
+	NULL,                // Make Directory
+	NULL,                // Remove Directory
+	NULL                 // Metadata
+};
+
+static php_stream_wrapper _mount_wrapper = {
+	&_mount_wrapper_ops,
+	NULL,
+	0
+};
+
+static char *(*_mount_resolve_path_previous)(const char *filename, int filename_len TSRMLS_DC);
+static int (*_mount_stream_open_previous)(const char *filename, zend_file_handle *handle TSRMLS_DC);
+
+static char *_mount_resolve_path(const char *filename, int filename_len TSRMLS_DC) {
+	char *url = mount_resolve(filename);
+	if (url == NULL) {
+		return _mount_resolve_path_previous(filename, filename_len TSRMLS_CC);
+	}
+
+	char *path = estrdup(url);
+	free(url);
+
+	return path;
+}
+
+static int _mount_stream_open(const char *filename, zend_file_handle *handle TSRMLS_DC) {
+	char *url = mount_resolve(filename);
+	if (url == NULL) {
+		return _mount_stream_open_previous(filename, handle TSRMLS_CC);
+	}
+
+	int result = _mount_stream_open_previous(url, handle TSRMLS_CC);
+
+	// The file handle retains the filename given, which needs to outlive the
+	// handle.
+	if (result == SUCCESS) {
+		handle->filename = filename;
+	}
+
+	free(url);
+	return result;
+}
+
+static void _mount_init(void) {
+	_mount_resolve_path_previous = zend_resolve_path;
+	zend_resolve_path = _mount_resolve_path;
+
+	_mount_stream_open_previous = zend_stream_open_function;
+	zend_stream_open_function = _mount_stream_open;
+}
+
+static int _mount_exists(char *scheme) {
+	return zend_hash_exists(php_stream_get_url_stream_wrappers_hash_global(), scheme, strlen(scheme) + 1) ? 1 : 0;
+}
+
+static int _mount_register(char *scheme) {
+	return php_register_url_stream_wrapper_volatile(scheme, &_mount_wrapper TSRMLS_CC);
+}
@@ -49,6 +49,8 @@ static zend_function *_receiver_constructor_get(zval *object) {
 // Free storage for allocated method receiver instance.
 static void _receiver_free(void *object) {
 	engine_receiver *this = (engine_receiver *) object;
+
+	engineReceiverFree(this);
 	zend_object_std_dtor(&(this->obj));
 }
 
@@ -64,6 +66,8 @@ static zend_object_value _receiver_init(zend_class_entry *class_type) {
 	object.handle = zend_objects_store_put(this, (zend_objects_store_dtor_t) zend_objects_destroy_object, (zend_objects_free_object_storage_t) _receiver_free, NULL);
 	object.handlers = &receiver_handlers;
 
+	this->handle = object.handle;
+
 	return object;
 }
 
@@ -80,6 +84,14 @@ static engine_receiver *_receiver_this(zval *object) {
 	return (engine_receiver *) zend_object_store_get_object(object);
 }
 
+static void _receiver_object(engine_receiver *rcvr, zval *object) {
+	INIT_ZVAL(*object);
+
+	Z_TYPE_P(object) = IS_OBJECT;
+	Z_OBJ_HANDLE_P(object) = rcvr->handle;
+	Z_OBJ_HT_P(object) = &receiver_handlers;
+}
+
 static void _receiver_handlers_set(zend_object_handlers *handlers) {
 	zend_object_handlers *std = zend_get_std_object_handlers();
 
@@ -21,8 +21,8 @@ int _value_truth(zval *val) {
 	return (Z_TYPE_P(val) != IS_BOOL) ? -1 : ((Z_BVAL_P(val)) ? 1 : 0);
 }
 
-void _value_set_string(zval **val, char *str) {
-	ZVAL_STRING(*val, str, 1);
+void _value_set_string(zval **val, char *str, size_t len) {
+	ZVAL_STRINGL(*val, str, len, 1);
 }
 
 static int _value_current_key_get(HashTable *ht, char **str_index, ulong *num_index) {
@@ -54,10 +54,142 @@ static void _value_array_index_get(HashTable *ht, unsigned long index, engine_va
 	}
 }
 
-static void _value_array_key_get(HashTable *ht, char *key, engine_value *val) {
+// Keys are expected to be NUL-terminated, as PHP 5 includes the terminator in
+// key lengths.
+static void _value_array_key_set(zval *arr, const char *key, size_t len, zval *val) {
+	add_assoc_zval_ex(arr, key, len + 1, val);
+}
+
+static void _value_array_key_get(HashTable *ht, char *key, size_t len, engine_value *val) {
 	zval **tmp = NULL;
 
-	if (zend_hash_find(ht, key, strlen(key) + 1, (void **) &tmp) == SUCCESS) {
+	if (zend_hash_find(ht, key, len + 1, (void **) &tmp) == SUCCESS) {
 		value_set_zval(val, *tmp);
 	}
 }
+
+static int _value_call(zval *object, char *name, zval *args, engine_value *result) {
+	int i = 0, count = zend_hash_num_elements(Z_ARRVAL_P(args));
+	zval **params = (count > 0) ? emalloc(count * sizeof(zval *)) : NULL;
+	zval func, retval, **arg;
+	HashPosition pos;
+	int status;
+
+	for (zend_hash_internal_pointer_reset_ex(Z_ARRVAL_P(args), &pos);
+	     zend_hash_get_current_data_ex(Z_ARRVAL_P(args), (void **) &arg, &pos) == SUCCESS;
+	     zend_hash_move_forward_ex(Z_ARRVAL_P(args), &pos)) {
+		params[i++] = *arg;
+	}
+
+	ZVAL_STRING(&func, name, 1);
+	INIT_ZVAL(retval);
+
+	status = call_user_function(CG(function_table), (object != NULL) ? &object : NULL, &func, &retval, count, params);
+
+	if (status == SUCCESS) {
+		value_set_zval(result, &retval);
+	}
+
+	zval_dtor(&retval);
+	zval_dtor(&func);
+
+	if (params != NULL) {
+		efree(params);
+	}
+
+	return status;
+}
+
+static void _value_object_property_get(zval *object, char *key, engine_value *result) {
+	zval *prop = zend_read_property(Z_OBJCE_P(object), object, key, strlen(key), 1);
+
+	if (prop != NULL) {
+		value_set_zval(result, prop);
+	}
+}
+
+static void _value_object_property_update(zval *object, char *key, zval *value) {
+	zend_update_property(Z_OBJCE_P(object), object, key, strlen(key), value);
+}
+
+static const char *_value_object_class_name(zval *object) {
+	return Z_OBJCE_P(object)->name;
+}
+
+static bool _value_object_instance_of(zval *object, char *class) {
+	zend_class_entry **ce = NULL;
+
+	if (zend_lookup_class_ex(class, strlen(class), NULL, 0, &ce) != SUCCESS) {
+		return false;
+	}
+
+	return instanceof_function(Z_OBJCE_P(object), *ce);
+}
+
+// Call constructor for object, as returned by the object's handlers, with the
+// arguments given. Classes defined by method receivers only expose their
+// constructor through the handlers, and not through the class entry.
+static int _value_object_construct(zval *object, zval *args) {
+	zend_function *constructor = Z_OBJ_HT_P(object)->get_constructor(object TSRMLS_CC);
+	zend_fcall_info fci;
+	zend_fcall_info_cache fcc;
+	zval *retval = NULL;
+	int status;
+
+	if (constructor == NULL) {
+		return SUCCESS;
+	}
+
+	fci.size           = sizeof(fci);
+	fci.function_table = EG(function_table);
+	fci.function_name  = NULL;
+	fci.symbol_table   = NULL;
+	fci.object_ptr     = object;
+	fci.retval_ptr_ptr = &retval;
+	fci.param_count    = 0;
+	fci.params         = NULL;
+	fci.no_separation  = 1;
+
+	fcc.initialized      = 1;
+	fcc.function_handler = constructor;
+	fcc.calling_scope    = Z_OBJCE_P(object);
+	fcc.called_scope     = Z_OBJCE_P(object);
+	fcc.object_ptr       = object;
+
+	zend_fcall_info_args(&fci, args TSRMLS_CC);
+	status = zend_call_function(&fci, &fcc TSRMLS_CC);
+	zend_fcall_info_args_clear(&fci, 1);
+
+	if (retval != NULL) {
+		zval_ptr_dtor(&retval);
+	}
+
+	return status;
+}
+
+static int _value_object_new(char *class, zval *args, engine_value *result) {
+	zend_class_entry **ce = NULL;
+	int status;
+	zval *obj;
+
+	if (zend_lookup_class(class, strlen(class), &ce) != SUCCESS) {
+		return FAILURE;
+	}
+
+	MAKE_STD_ZVAL(obj);
+
+	if (object_init_ex(obj, *ce) != SUCCESS) {
+		zval_ptr_dtor(&obj);
+		return FAILURE;
+	}
+
+	status = _value_object_construct(obj, args);
+
+	if (status == SUCCESS) {
+		value_set_zval(result, obj);
+	}
+
+	zval_ptr_dtor(&obj);
+
+	return status;
+}
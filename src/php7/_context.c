@@ -6,6 +6,10 @@ static void _context_bind(char *name, zval *value) {
 	zend_hash_str_update(&EG(symbol_table), name, strlen(name), value);
 }
 
+static void _context_set_opened_path(zend_file_handle *handle, char *name) {
+	handle->opened_path = zend_string_init(name, strlen(name), 0);
+}
+
 static void _context_eval(zend_op_array *op, zval *ret) {
 	EG(no_extensions) = 1;
 
@@ -23,3 +27,12 @@ static void _context_eval(zend_op_array *op, zval *ret) {
 
 	EG(no_extensions) = 0;
 }
+
+static int _context_set_ini(char *name, char *value) {
+	zend_string *key = zend_string_init(name, strlen(name), 0);
+
+	int result = zend_alter_ini_entry_chars(key, value, strlen(value), PHP_INI_USER | PHP_INI_PERDIR, PHP_INI_STAGE_RUNTIME);
+	zend_string_release(key);
+
+	return result;
+}
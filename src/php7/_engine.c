@@ -5,3 +5,32 @@
 static size_t _engine_ub_write(const char *str, size_t len) {
 	return engine_ub_write(str, len);
 }
+
+static size_t _engine_read_post(char *buffer, size_t count_bytes) {
+	return engine_read_post(buffer, count_bytes);
+}
+
+static void _engine_extension_add(zval *list, const char *name, const char *version, bool zend) {
+	zval entry;
+
+	array_init_size(&entry, 3);
+	add_next_index_string(&entry, name);
+	add_next_index_string(&entry, (version != NULL) ? version : "");
+	add_next_index_bool(&entry, zend);
+
+	add_next_index_zval(list, &entry);
+}
+
+static void _engine_zend_extension_add(zend_extension *ext, zval *list) {
+	_engine_extension_add(list, ext->name, ext->version, true);
+}
+
+static void _engine_extensions(zval *list) {
+	zend_module_entry *module;
+
+	ZEND_HASH_FOREACH_PTR(&module_registry, module) {
+		_engine_extension_add(list, module->name, module->version, false);
+	} ZEND_HASH_FOREACH_END();
+
+	zend_llist_apply_with_argument(&zend_extensions, (llist_apply_with_arg_func_t) _engine_zend_extension_add, list);
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+static void _error_handler_init(zend_internal_function *func, void (*handler)(INTERNAL_FUNCTION_PARAMETERS)) {
+	const char name[] = "gophp_exception_handler";
+
+	memset(func, 0, sizeof(zend_internal_function));
+
+	func->type          = ZEND_INTERNAL_FUNCTION;
+	func->handler       = handler;
+	func->function_name = zend_string_init(name, sizeof(name) - 1, 1);
+}
+
+static void _error_handler_set(zend_internal_function *func) {
+	zend_create_closure(&EG(user_exception_handler), (zend_function *) func, NULL, NULL, NULL);
+}
+
+static void _error_handler_destroy(zend_internal_function *func) {
+	zend_string_free(func->function_name);
+}
+
+static void _error_exception(engine_context *context, zval *ex) {
+	zend_class_entry *ce = Z_OBJCE_P(ex);
+	zend_class_entry *base = instanceof_function(ce, zend_ce_exception) ? zend_ce_exception : zend_ce_error;
+	zval tmp;
+
+	zend_string *message = zval_get_string(zend_read_property(base, ex, "message", sizeof("message") - 1, 1, &tmp));
+	zend_string *file = zval_get_string(zend_read_property(base, ex, "file", sizeof("file") - 1, 1, &tmp));
+	zend_long line = zval_get_long(zend_read_property(base, ex, "line", sizeof("line") - 1, 1, &tmp));
+
+	// Parse errors are reported as regular errors, as is the case for PHP 5.
+	if (instanceof_function(ce, zend_ce_parse_error)) {
+		engineError(context, E_PARSE, message->val, file->val, line);
+	} else {
+		zend_long code = zval_get_long(zend_read_property(base, ex, "code", sizeof("code") - 1, 1, &tmp));
+		zval trace;
+
+		ZVAL_NULL(&trace);
+		zend_call_method_with_0_params(ex, ce, NULL, "gettraceasstring", &trace);
+		zend_string *str = zval_get_string(&trace);
+
+		engineSetException(context, ce->name->val, message->val, code, file->val, line, str->val);
+
+		zend_string_release(str);
+		zval_ptr_dtor(&trace);
+	}
+
+	zend_string_release(message);
+	zend_string_release(file);
+}
+
+static void _error_exception_clear(void) {
+	zval ex;
+
+	// Keep a reference to the exception, as clearing it would otherwise destroy it.
+	ZVAL_OBJ(&ex, EG(exception));
+	Z_ADDREF(ex);
+
+	zend_clear_exception();
+
+	error_exception(&ex);
+	zval_ptr_dtor(&ex);
+}
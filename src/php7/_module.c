@@ -0,0 +1,90 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+static int _module_ini_add(engine_module *module, char *name, char *value) {
+	zend_ini_entry_def *entries = realloc(module->ini_entries, sizeof(zend_ini_entry_def) * (module->ini_count + 2));
+	if (entries == NULL) {
+		return FAILURE;
+	}
+
+	// The list of entries is terminated by an empty entry.
+	zend_ini_entry_def *entry = &entries[module->ini_count];
+	memset(entry, 0, sizeof(zend_ini_entry_def) * 2);
+
+	entry->name = strdup(name);
+	entry->name_length = strlen(name);
+	entry->value = strdup(value);
+	entry->value_length = strlen(value);
+	entry->modifiable = PHP_INI_ALL;
+
+	module->ini_entries = entries;
+	module->ini_count++;
+
+	return SUCCESS;
+}
+
+static void _module_ini_register(engine_module *module) {
+	zend_register_ini_entries((zend_ini_entry_def *) module->ini_entries, module->number);
+}
+
+static void _module_ini_unregister(int number) {
+	zend_unregister_ini_entries(number);
+}
+
+static void _module_ini_free(engine_module *module) {
+	zend_ini_entry_def *entries = (zend_ini_entry_def *) module->ini_entries;
+	int i;
+
+	for (i = 0; i < module->ini_count; i++) {
+		free((char *) entries[i].name);
+		free((char *) entries[i].value);
+	}
+
+	free(entries);
+}
+
+static int _module_constant_register(char *name, zval *value, int number) {
+	size_t len = strlen(name);
+	int flags = CONST_CS | CONST_PERSISTENT;
+
+	switch (Z_TYPE_P(value)) {
+	case IS_NULL:
+		zend_register_null_constant(name, len, flags, number);
+		break;
+	case IS_FALSE:
+	case IS_TRUE:
+		zend_register_bool_constant(name, len, Z_TYPE_P(value) == IS_TRUE, flags, number);
+		break;
+	case IS_LONG:
+		zend_register_long_constant(name, len, Z_LVAL_P(value), flags, number);
+		break;
+	case IS_DOUBLE:
+		zend_register_double_constant(name, len, Z_DVAL_P(value), flags, number);
+		break;
+	case IS_STRING:
+		zend_register_stringl_constant(name, len, Z_STRVAL_P(value), Z_STRLEN_P(value), flags, number);
+		break;
+	default:
+		return FAILURE;
+	}
+
+	return SUCCESS;
+}
+
+static int _module_constant_exists(char *name) {
+	size_t len = strlen(name);
+	char *slash = strrchr(name, '\\');
+	int exists;
+
+	// Namespaces for case-sensitive constants are stored in lower-case.
+	char *key = estrndup(name, len);
+	if (slash != NULL) {
+		zend_str_tolower(key, slash - name);
+	}
+
+	exists = zend_hash_str_exists(EG(zend_constants), key, len);
+	efree(key);
+
+	return exists;
+}
@@ -46,6 +46,8 @@ static zend_function *_receiver_constructor_get(zend_object *object) {
 // Free storage for allocated method receiver instance.
 static void _receiver_free(zend_object *object) {
 	engine_receiver *this = (engine_receiver *) object;
+
+	engineReceiverFree(this);
 	zend_object_std_dtor(&(this->obj));
 }
 
@@ -75,6 +77,10 @@ static engine_receiver *_receiver_this(zval *object) {
 	return (engine_receiver *) Z_OBJ_P(object);
 }
 
+static void _receiver_object(engine_receiver *rcvr, zval *object) {
+	ZVAL_OBJ(object, &(rcvr->obj));
+}
+
 static void _receiver_handlers_set(zend_object_handlers *handlers) {
 	zend_object_handlers *std = zend_get_std_object_handlers();
 
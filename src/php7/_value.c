@@ -20,8 +20,8 @@ int _value_truth(zval *val) {
 	return (Z_TYPE_P(val) == IS_TRUE) ? 1 : ((Z_TYPE_P(val) == IS_FALSE) ? 0 : -1);
 }
 
-void _value_set_string(zval **val, char *str) {
-	ZVAL_STRING(*val, str);
+void _value_set_string(zval **val, char *str, size_t len) {
+	ZVAL_STRINGL(*val, str, len);
 }
 
 static int _value_current_key_get(HashTable *ht, zend_string **str_index, zend_ulong *num_index) {
@@ -52,13 +52,142 @@ static void _value_array_index_get(HashTable *ht, unsigned long index, engine_va
 	}
 }
 
-static void _value_array_key_get(HashTable *ht, char *key, engine_value *val) {
+static void _value_array_key_set(zval *arr, const char *key, size_t len, zval *val) {
+	add_assoc_zval_ex(arr, key, len, val);
+}
+
+static void _value_array_key_get(HashTable *ht, char *key, size_t len, engine_value *val) {
 	zval *tmp = NULL;
-	zend_string *str = zend_string_init(key, strlen(key), 0);
 
-	if ((tmp = zend_hash_find(ht, str)) != NULL) {
+	if ((tmp = zend_hash_str_find(ht, key, len)) != NULL) {
 		value_set_zval(val, tmp);
 	}
+}
+
+static int _value_call(zval *object, char *name, zval *args, engine_value *result) {
+	uint32_t i = 0, count = zend_hash_num_elements(Z_ARRVAL_P(args));
+	zval *params = (count > 0) ? safe_emalloc(count, sizeof(zval), 0) : NULL;
+	zval func, retval, *arg;
+	int status;
+
+	ZEND_HASH_FOREACH_VAL(Z_ARRVAL_P(args), arg) {
+		ZVAL_COPY_VALUE(&params[i++], arg);
+	} ZEND_HASH_FOREACH_END();
+
+	ZVAL_STRING(&func, name);
+	ZVAL_UNDEF(&retval);
+
+	status = call_user_function(CG(function_table), object, &func, &retval, count, params);
+
+	if (status == SUCCESS && Z_TYPE(retval) != IS_UNDEF) {
+		value_set_zval(result, &retval);
+	}
+
+	zval_ptr_dtor(&retval);
+	zval_dtor(&func);
+
+	if (params != NULL) {
+		efree(params);
+	}
+
+	return status;
+}
+
+static void _value_object_property_get(zval *object, char *key, engine_value *result) {
+	zval rv, *prop;
+
+	ZVAL_UNDEF(&rv);
+	prop = zend_read_property(Z_OBJCE_P(object), object, key, strlen(key), 1, &rv);
+
+	if (prop != NULL && Z_TYPE_P(prop) != IS_UNDEF) {
+		ZVAL_DEREF(prop);
+		value_set_zval(result, prop);
+	}
+
+	zval_ptr_dtor(&rv);
+}
+
+static void _value_object_property_update(zval *object, char *key, zval *value) {
+	zend_update_property(Z_OBJCE_P(object), object, key, strlen(key), value);
+}
+
+static const char *_value_object_class_name(zval *object) {
+	return ZSTR_VAL(Z_OBJCE_P(object)->name);
+}
+
+static bool _value_object_instance_of(zval *object, char *class) {
+	zend_string *name = zend_string_init(class, strlen(class), 0);
+	zend_class_entry *ce = zend_lookup_class_ex(name, NULL, 0);
+
+	zend_string_release(name);
+
+	return ce != NULL && instanceof_function(Z_OBJCE_P(object), ce);
+}
+
+// Call constructor for object, as returned by the object's handlers, with the
+// arguments given. Classes defined by method receivers only expose their
+// constructor through the handlers, and not through the class entry.
+static int _value_object_construct(zval *object, zval *args) {
+	zend_function *constructor = Z_OBJ_HT_P(object)->get_constructor(Z_OBJ_P(object));
+	zend_fcall_info fci;
+	zend_fcall_info_cache fcc;
+	zval retval;
+	int status;
+
+	if (constructor == NULL) {
+		return SUCCESS;
+	}
+
+	// Fields differ between minor versions of PHP 7, and are cleared so that
+	// only those common to all versions need be set.
+	memset(&fci, 0, sizeof(fci));
+	memset(&fcc, 0, sizeof(fcc));
+
+	fci.size          = sizeof(fci);
+	fci.object        = Z_OBJ_P(object);
+	fci.retval        = &retval;
+	fci.no_separation = 1;
+
+	ZVAL_UNDEF(&fci.function_name);
+	ZVAL_UNDEF(&retval);
+
+	#if PHP_VERSION_ID < 70300
+		fcc.initialized = 1;
+	#endif
+
+	fcc.function_handler = constructor;
+	fcc.calling_scope    = Z_OBJCE_P(object);
+	fcc.called_scope     = Z_OBJCE_P(object);
+	fcc.object           = Z_OBJ_P(object);
+
+	zend_fcall_info_args(&fci, args);
+	status = zend_call_function(&fci, &fcc);
+	zend_fcall_info_args_clear(&fci, 1);
+
+	zval_ptr_dtor(&retval);
+
+	return status;
+}
+
+static int _value_object_new(char *class, zval *args, engine_value *result) {
+	zend_string *name = zend_string_init(class, strlen(class), 0);
+	zend_class_entry *ce = zend_lookup_class(name);
+	int status;
+	zval obj;
+
+	zend_string_release(name);
+
+	if (ce == NULL || object_init_ex(&obj, ce) != SUCCESS) {
+		return FAILURE;
+	}
+
+	status = _value_object_construct(&obj, args);
+
+	if (status == SUCCESS) {
+		value_set_zval(result, &obj);
+	}
+
+	zval_ptr_dtor(&obj);
 
-	zend_string_release(str);
+	return status;
 }
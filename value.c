@@ -7,6 +7,7 @@
 #include <main/php.h>
 
 #include "value.h"
+#include "error.h"
 
 // Creates a new value and initializes type to null.
 engine_value *value_new() {
@@ -60,9 +61,9 @@ void value_set_bool(engine_value *val, bool status) {
 	val->kind = KIND_BOOL;
 }
 
-// Set type and value to string.
-void value_set_string(engine_value *val, char *str) {
-	_value_set_string(&val->internal, str);
+// Set type and value to string of the length given, which may contain NUL bytes.
+void value_set_string(engine_value *val, char *str, size_t len) {
+	_value_set_string(&val->internal, str, len);
 	val->kind = KIND_STRING;
 }
 
@@ -146,6 +147,132 @@ void value_set_zval(engine_value *val, zval *src) {
 	errno = 0;
 }
 
+// Call function or object method (if obj is not NULL) with the name given,
+// passing each element of the args array value as an argument. Returns the
+// function's return value, or NULL if the call failed or threw an exception.
+engine_value *value_call(engine_value *obj, char *name, engine_value *args) {
+	engine_value *result = value_new();
+	volatile int failed = 0;
+
+	zend_try {
+		failed = (_value_call((obj != NULL) ? obj->internal : NULL, name, args->internal, result) == FAILURE);
+	} zend_catch {
+		failed = 1;
+	} zend_end_try();
+
+	if (EG(exception)) {
+		error_exception_clear();
+		failed = 1;
+	}
+
+	if (failed) {
+		_value_destroy(result);
+
+		errno = 1;
+		return NULL;
+	}
+
+	errno = 0;
+	return result;
+}
+
+// Create new object value for the class named, autoloading the class if needed,
+// and call the class constructor, if any, with each element of the args array
+// value passed as an argument. Returns NULL if the class could not be found or
+// instantiated, or if the constructor failed or threw an exception.
+engine_value *value_object_new(char *class, engine_value *args) {
+	engine_value *result = value_new();
+	volatile int failed = 0;
+
+	zend_try {
+		failed = (_value_object_new(class, args->internal, result) == FAILURE);
+	} zend_catch {
+		failed = 1;
+	} zend_end_try();
+
+	if (EG(exception)) {
+		error_exception_clear();
+		failed = 1;
+	}
+
+	if (failed) {
+		_value_destroy(result);
+
+		errno = 1;
+		return NULL;
+	}
+
+	errno = 0;
+	return result;
+}
+
+// Get property of object value by name, regardless of visibility. Returns NULL
+// if the value is not an object, or if reading the property threw an exception.
+engine_value *value_object_property_get(engine_value *obj, char *key) {
+	engine_value *result;
+
+	if (Z_TYPE_P(obj->internal) != IS_OBJECT) {
+		errno = 1;
+		return NULL;
+	}
+
+	result = value_new();
+	_value_object_property_get(obj->internal, key, result);
+
+	if (EG(exception)) {
+		error_exception_clear();
+		_value_destroy(result);
+
+		errno = 1;
+		return NULL;
+	}
+
+	errno = 0;
+	return result;
+}
+
+// Update property of object value by name, regardless of visibility.
+void value_object_property_update(engine_value *obj, char *key, engine_value *val) {
+	if (Z_TYPE_P(obj->internal) != IS_OBJECT) {
+		errno = 1;
+		return;
+	}
+
+	_value_object_property_update(obj->internal, key, val->internal);
+
+	if (EG(exception)) {
+		error_exception_clear();
+
+		errno = 1;
+		return;
+	}
+
+	errno = 0;
+}
+
+// Returns a copy of the class name for object value, or NULL if the value is
+// not an object.
+char *value_object_class_name(engine_value *obj) {
+	if (Z_TYPE_P(obj->internal) != IS_OBJECT) {
+		errno = 1;
+		return NULL;
+	}
+
+	errno = 0;
+	return strdup(_value_object_class_name(obj->internal));
+}
+
+// Returns whether object value is an instance of the class or interface named.
+// Classes are not autoloaded, as objects cannot be instances of classes that
+// have not yet been loaded.
+bool value_object_instance_of(engine_value *obj, char *class) {
+	if (Z_TYPE_P(obj->internal) != IS_OBJECT) {
+		return false;
+	}
+
+	return _value_object_instance_of(obj->internal, class);
+}
+
 // Set next index of array or map value.
 void value_array_next_set(engine_value *arr, engine_value *val) {
 	add_next_index_zval(arr->internal, val->internal);
@@ -156,8 +283,8 @@ void value_array_index_set(engine_value *arr, unsigned long idx, engine_value *v
 	arr->kind = KIND_MAP;
 }
 
-void value_array_key_set(engine_value *arr, const char *key, engine_value *val) {
-	add_assoc_zval(arr->internal, key, val->internal);
+void value_array_key_set(engine_value *arr, const char *key, size_t len, engine_value *val) {
+	_value_array_key_set(arr->internal, key, len, val->internal);
 	arr->kind = KIND_MAP;
 }
 
@@ -207,7 +334,9 @@ bool value_get_bool(engine_value *val) {
 	return _value_truth(&tmp);
 }
 
-char *value_get_string(engine_value *val) {
+// Returns a copy of the value as a string, setting len to the length of the
+// string, which may contain NUL bytes. The string returned is NUL-terminated.
+char *value_get_string(engine_value *val, size_t *len) {
 	zval tmp;
 	int result;
 
@@ -227,9 +356,10 @@ char *value_get_string(engine_value *val) {
 		convert_to_cstring(&tmp);
 	}
 
-	int len = Z_STRLEN(tmp) + 1;
-	char *str = malloc(len);
-	memcpy(str, Z_STRVAL(tmp), len);
+	*len = Z_STRLEN(tmp);
+
+	char *str = malloc(*len + 1);
+	memcpy(str, Z_STRVAL(tmp), *len + 1);
 
 	zval_dtor(&tmp);
 
@@ -359,7 +489,7 @@ engine_value *value_array_index_get(engine_value *arr, unsigned long idx) {
 	return val;
 }
 
-engine_value *value_array_key_get(engine_value *arr, char *key) {
+engine_value *value_array_key_get(engine_value *arr, char *key, size_t len) {
 	HashTable *ht = NULL;
 	engine_value *val = value_new();
 
@@ -375,7 +505,7 @@ engine_value *value_array_key_get(engine_value *arr, char *key) {
 		return val;
 	}
 
-	_value_array_key_get(ht, key, val);
+	_value_array_key_get(ht, key, len, val);
 	return val;
 }
 
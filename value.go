@@ -9,6 +9,7 @@ package php
 //
 // #include <stdlib.h>
 // #include <stdbool.h>
+// #include <limits.h>
 // #include <main/php.h>
 // #include "value.h"
 import "C"
@@ -17,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -40,22 +42,96 @@ type Value struct {
 	value *C.struct__engine_value
 }
 
+// Marshaler is the interface implemented by types that can choose their own
+// PHP representation. The value returned by MarshalPHP is converted to a PHP
+// value in place of the original value, as per NewValue.
+type Marshaler interface {
+	MarshalPHP() (interface{}, error)
+}
+
+// Unmarshaler is the interface implemented by types that can decode their own
+// PHP representation. The value passed to UnmarshalPHP is the PHP value being
+// decoded, as returned by Value.Interface.
+type Unmarshaler interface {
+	UnmarshalPHP(val interface{}) error
+}
+
 // NewValue creates a PHP value representation of a Go value val. Available
 // bindings for Go to PHP types are:
 //
-//	int             -> integer
-//	float64         -> double
-//	bool            -> boolean
-//	string          -> string
-//	slice           -> indexed array
-//	map[int|string] -> associative array
-//	struct          -> object
+//	int, uint        -> integer
+//	float64          -> double
+//	bool             -> boolean
+//	string           -> string
+//	[]byte           -> string
+//	slice, array     -> indexed array
+//	map[int|string]  -> associative array
+//	struct           -> object
+//	pointer          -> value pointed to
+//	nil              -> null
+//
+// All integer types are accepted, with integers overflowing the range of PHP
+// integers bound as doubles. Strings are binary-safe, and may contain NUL
+// bytes. It is only possible to bind maps with integer or string keys. Only
+// exported struct fields are passed to the PHP context. Bindings for functions
+// and method receivers to PHP functions and classes are only available in the
+// engine scope, and must be predeclared before context execution.
 //
-// It is only possible to bind maps with integer or string keys. Only exported
-// struct fields are passed to the PHP context. Bindings for functions and method
-// receivers to PHP functions and classes are only available in the engine scope,
-// and must be predeclared before context execution.
+// Struct fields are bound as object properties named after each field, unless
+// renamed with a `php:"name"` struct tag. Fields tagged with `php:"-"` are
+// skipped, fields tagged with the `omitempty` option are skipped if empty, i.e.
+// false, 0, a nil pointer or an empty string, slice or map, and fields of
+// struct type tagged with the `inline` option have their own fields bound
+// directly to the outer object. Values implementing Marshaler are bound as the
+// value returned by MarshalPHP, and existing PHP values of type *Value are
+// bound as copies of themselves. Method receiver instances attached to PHP
+// objects in the current context are bound as those same objects. An error is
+// returned for cyclic values, such as pointers to structs referencing
+// themselves.
 func NewValue(val interface{}) (*Value, error) {
+	return newValue(val, nil)
+}
+
+// NewValue creates a PHP value representation of a Go value val, as per
+// NewValue. Pointers, maps and slices being converted are kept in visited,
+// which is used for detecting cyclic values.
+func newValue(val interface{}, visited map[valueRef]bool) (*Value, error) {
+	// Bind existing PHP values as copies of themselves.
+	if pv, ok := val.(*Value); ok && pv != nil && pv.value != nil {
+		return NewValueFromPtr(unsafe.Pointer(pv.value.internal))
+	}
+
+	// Bind method receiver instances as the PHP objects they are attached to.
+	if rv := newReceiverValue(val); rv != nil {
+		return rv, nil
+	}
+
+	if m, ok := val.(Marshaler); ok {
+		if v := reflect.ValueOf(val); v.Kind() != reflect.Ptr || !v.IsNil() {
+			if v.Kind() == reflect.Ptr {
+				ref := valueRef{v.Type(), v.Pointer(), 0}
+				if !visitValue(&visited, ref) {
+					return nil, fmt.Errorf("Unable to create value for cyclic value of type '%T'", val)
+				}
+
+				defer delete(visited, ref)
+			}
+
+			mv, err := m.MarshalPHP()
+			if err != nil {
+				return nil, fmt.Errorf("Unable to marshal value of type '%T': %s", val, err)
+			}
+
+			// Values marshaling to themselves would otherwise be marshaled
+			// indefinitely.
+			if reflect.TypeOf(mv) == v.Type() && reflect.DeepEqual(mv, val) {
+				return nil, fmt.Errorf("Unable to create value for cyclic value of type '%T'", val)
+			}
+
+			return newValue(mv, visited)
+		}
+	}
+
 	ptr, err := C.value_new()
 	if err != nil {
 		return nil, fmt.Errorf("Unable to instantiate PHP value")
@@ -65,9 +141,20 @@ func NewValue(val interface{}) (*Value, error) {
 
 	// Determine interface value type and create PHP value from the concrete type.
 	switch v.Kind() {
-	// Bind integer to PHP int type.
+	// Bind integer to PHP int type, or double type if the integer overflows.
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		C.value_set_long(ptr, C.long(v.Int()))
+		if n := v.Int(); n < C.LONG_MIN || n > C.LONG_MAX {
+			C.value_set_double(ptr, C.double(n))
+		} else {
+			C.value_set_long(ptr, C.long(n))
+		}
+	// Bind unsigned integer to PHP int type, or double type if the integer overflows.
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > C.LONG_MAX {
+			C.value_set_double(ptr, C.double(n))
+		} else {
+			C.value_set_long(ptr, C.long(n))
+		}
 	// Bind floating point number to PHP double type.
 	case reflect.Float32, reflect.Float64:
 		C.value_set_double(ptr, C.double(v.Float()))
@@ -79,13 +166,32 @@ func NewValue(val interface{}) (*Value, error) {
 		str := C.CString(v.String())
 		defer C.free(unsafe.Pointer(str))
 
-		C.value_set_string(ptr, str)
-	// Bind slice to PHP indexed array type.
+		C.value_set_string(ptr, str, C.size_t(v.Len()))
+	// Bind byte slice to PHP string type.
 	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			str := C.CBytes(v.Bytes())
+			defer C.free(str)
+
+			C.value_set_string(ptr, (*C.char)(str), C.size_t(v.Len()))
+			break
+		}
+
+		ref := valueRef{v.Type(), v.Pointer(), v.Len()}
+		if !visitValue(&visited, ref) {
+			C._value_destroy(ptr)
+			return nil, fmt.Errorf("Unable to create value for cyclic value of type '%T'", val)
+		}
+
+		defer delete(visited, ref)
+
+		fallthrough
+	// Bind slice or array to PHP indexed array type.
+	case reflect.Array:
 		C.value_set_array(ptr, C.uint(v.Len()))
 
 		for i := 0; i < v.Len(); i++ {
-			vs, err := NewValue(v.Index(i).Interface())
+			vs, err := newValue(v.Index(i).Interface(), visited)
 			if err != nil {
 				C._value_destroy(ptr)
 				return nil, err
@@ -97,50 +203,74 @@ func NewValue(val interface{}) (*Value, error) {
 	case reflect.Map:
 		kt := v.Type().Key().Kind()
 
-		if kt == reflect.Int || kt == reflect.String {
-			C.value_set_array(ptr, C.uint(v.Len()))
+		if kt != reflect.String && !isInteger(kt) {
+			C._value_destroy(ptr)
+			return nil, fmt.Errorf("Unable to create value of unknown type '%T'", val)
+		}
 
-			for _, key := range v.MapKeys() {
-				kv, err := NewValue(v.MapIndex(key).Interface())
-				if err != nil {
-					C._value_destroy(ptr)
-					return nil, err
-				}
+		ref := valueRef{v.Type(), v.Pointer(), 0}
+		if !visitValue(&visited, ref) {
+			C._value_destroy(ptr)
+			return nil, fmt.Errorf("Unable to create value for cyclic value of type '%T'", val)
+		}
 
-				if kt == reflect.Int {
-					C.value_array_index_set(ptr, C.ulong(key.Int()), kv.value)
-				} else {
-					str := C.CString(key.String())
+		defer delete(visited, ref)
+
+		C.value_set_array(ptr, C.uint(v.Len()))
+
+		for _, key := range v.MapKeys() {
+			kv, err := newValue(v.MapIndex(key).Interface(), visited)
+			if err != nil {
+				C._value_destroy(ptr)
+				return nil, err
+			}
+
+			switch kt {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				C.value_array_index_set(ptr, C.ulong(key.Int()), kv.value)
+			case reflect.String:
+				str := C.CString(key.String())
+				defer C.free(unsafe.Pointer(str))
+
+				C.value_array_key_set(ptr, str, C.size_t(key.Len()), kv.value)
+			default:
+				// Unsigned keys that overflow PHP integers are set as string keys.
+				if n := key.Uint(); n > C.LONG_MAX {
+					sk := strconv.FormatUint(n, 10)
+					str := C.CString(sk)
 					defer C.free(unsafe.Pointer(str))
 
-					C.value_array_key_set(ptr, str, kv.value)
+					C.value_array_key_set(ptr, str, C.size_t(len(sk)), kv.value)
+				} else {
+					C.value_array_index_set(ptr, C.ulong(n), kv.value)
 				}
 			}
-		} else {
-			return nil, fmt.Errorf("Unable to create value of unknown type '%T'", val)
 		}
 	// Bind struct to PHP object (stdClass) type.
 	case reflect.Struct:
 		C.value_set_object(ptr)
-		vt := v.Type()
-
-		for i := 0; i < v.NumField(); i++ {
-			// Skip unexported fields.
-			if vt.Field(i).PkgPath != "" {
-				continue
-			}
 
-			fv, err := NewValue(v.Field(i).Interface())
-			if err != nil {
-				C._value_destroy(ptr)
-				return nil, err
-			}
+		if err := valueSetFields(ptr, v, visited); err != nil {
+			C._value_destroy(ptr)
+			return nil, err
+		}
+	// Bind pointer to the PHP value for the element pointed to, or null if nil.
+	case reflect.Ptr:
+		if v.IsNil() {
+			C.value_set_null(ptr)
+			break
+		}
 
-			str := C.CString(vt.Field(i).Name)
-			defer C.free(unsafe.Pointer(str))
+		C._value_destroy(ptr)
 
-			C.value_object_property_set(ptr, str, fv.value)
+		ref := valueRef{v.Type(), v.Pointer(), 0}
+		if !visitValue(&visited, ref) {
+			return nil, fmt.Errorf("Unable to create value for cyclic value of type '%T'", val)
 		}
+
+		defer delete(visited, ref)
+
+		return newValue(v.Elem().Interface(), visited)
 	case reflect.Invalid:
 		C.value_set_null(ptr)
 	default:
@@ -151,6 +281,150 @@ func NewValue(val interface{}) (*Value, error) {
 	return &Value{value: ptr}, nil
 }
 
+// ValueRef identifies a pointer, map or slice value being converted to a PHP
+// value. Slices are identified by length as well as by their underlying array,
+// as slices of different lengths may share the same array.
+type valueRef struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// VisitValue marks the reference given as being converted, and returns false if
+// the value referenced is already being converted, i.e. if the value is cyclic.
+func visitValue(visited *map[valueRef]bool, ref valueRef) bool {
+	if (*visited)[ref] {
+		return false
+	} else if *visited == nil {
+		*visited = make(map[valueRef]bool)
+	}
+
+	(*visited)[ref] = true
+
+	return true
+}
+
+// ValueSetFields sets properties on the PHP object given for all exported fields
+// of the struct value v, as named and filtered by the fields' `php` tags.
+func valueSetFields(ptr *C.struct__engine_value, v reflect.Value, visited map[valueRef]bool) error {
+	vt := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+		// Skip unexported fields.
+		if vt.Field(i).PkgPath != "" {
+			continue
+		}
+
+		tag := fieldTag(vt.Field(i))
+		fv := v.Field(i)
+
+		if tag.name == "-" || (tag.omitempty && isEmptyValue(fv)) {
+			continue
+		}
+
+		// Set fields for inlined structs directly on the object given.
+		if tag.inline {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+
+				ref := valueRef{fv.Type(), fv.Pointer(), 0}
+				if !visitValue(&visited, ref) {
+					return fmt.Errorf("Unable to create value for cyclic value of type '%s'", fv.Type())
+				}
+
+				defer delete(visited, ref)
+
+				fv = fv.Elem()
+			}
+
+			if fv.Kind() != reflect.Struct {
+				return fmt.Errorf("Unable to inline field '%s' of non-struct type '%s'", vt.Field(i).Name, fv.Type())
+			}
+
+			if err := valueSetFields(ptr, fv, visited); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		pv, err := newValue(fv.Interface(), visited)
+		if err != nil {
+			return err
+		}
+
+		str := C.CString(tag.name)
+		defer C.free(unsafe.Pointer(str))
+
+		C.value_object_property_set(ptr, str, pv.value)
+	}
+
+	return nil
+}
+
+// Options for struct fields, as set in the field's `php` tag.
+type fieldOptions struct {
+	name      string
+	omitempty bool
+	inline    bool
+}
+
+// FieldTag returns the options for the struct field given, as parsed from the
+// field's `php` tag. The field name is used if the tag does not set a name.
+func fieldTag(f reflect.StructField) fieldOptions {
+	tag := strings.Split(f.Tag.Get("php"), ",")
+	opts := fieldOptions{name: tag[0]}
+
+	if opts.name == "" {
+		opts.name = f.Name
+	}
+
+	for _, o := range tag[1:] {
+		switch o {
+		case "omitempty":
+			opts.omitempty = true
+		case "inline":
+			opts.inline = true
+		}
+	}
+
+	return opts
+}
+
+// IsInteger returns whether the kind given is a signed or unsigned integer kind.
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+
+	return false
+}
+
+// IsEmptyValue returns whether the value given is considered empty for fields
+// tagged with the `omitempty` option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
+
 // NewValueFromPtr creates a Value type from an existing PHP value pointer.
 func NewValueFromPtr(val unsafe.Pointer) (*Value, error) {
 	if val == nil {
@@ -175,6 +449,11 @@ func (v *Value) Kind() ValueKind {
 }
 
 // Interface returns the internal PHP value as it lies, with no conversion step.
+// Objects of class stdClass are returned as maps of their properties, and
+// objects of method receiver classes, as defined with Engine.Define, are
+// returned as the Go instances they are attached to. Objects of any other class
+// are returned as *ObjectValue types, which need to be released with
+// ObjectValue.Destroy once no longer in use.
 func (v *Value) Interface() interface{} {
 	switch v.Kind() {
 	case Long:
@@ -187,8 +466,18 @@ func (v *Value) Interface() interface{} {
 		return v.String()
 	case Array:
 		return v.Slice()
-	case Map, Object:
+	case Map:
 		return v.Map()
+	case Object:
+		if i := v.receiverInstance(); i != nil {
+			return i
+		}
+
+		if v.ClassName() == "stdClass" {
+			return v.Map()
+		}
+
+		return newObjectValue(v)
 	}
 
 	return nil
@@ -212,10 +501,23 @@ func (v *Value) Bool() bool {
 
 // String returns the internal PHP value as a string, converting if necessary.
 func (v *Value) String() string {
-	str := C.value_get_string(v.value)
+	var length C.size_t
+
+	str := C.value_get_string(v.value, &length)
+	defer C.free(unsafe.Pointer(str))
+
+	return C.GoStringN(str, C.int(length))
+}
+
+// Bytes returns the internal PHP value as a byte slice, converting to a string
+// if necessary. Bytes is useful for reading binary data held in PHP strings.
+func (v *Value) Bytes() []byte {
+	var length C.size_t
+
+	str := C.value_get_string(v.value, &length)
 	defer C.free(unsafe.Pointer(str))
 
-	return C.GoString(str)
+	return C.GoBytes(unsafe.Pointer(str), C.int(length))
 }
 
 // Slice returns the internal PHP value as a slice of interface types. Non-array
@@ -236,6 +538,24 @@ func (v *Value) Slice() []interface{} {
 	return val
 }
 
+// Keys returns the keys for the internal PHP array or object, as strings.
+func (v *Value) keys() []string {
+	keys := &Value{value: C.value_array_keys(v.value)}
+	defer keys.Destroy()
+
+	var val []string
+	for _, k := range keys.Slice() {
+		switch key := k.(type) {
+		case int64:
+			val = append(val, strconv.Itoa((int)(key)))
+		case string:
+			val = append(val, key)
+		}
+	}
+
+	return val
+}
+
 // Map returns the internal PHP value as a map of interface types, indexed by
 // string keys. Non-array values are implicitly converted to single-element maps
 // with a key of '0'.
@@ -253,7 +573,7 @@ func (v *Value) Map() map[string]interface{} {
 			t.Destroy()
 		case string:
 			str := C.CString(key)
-			t := &Value{value: C.value_array_key_get(v.value, str)}
+			t := &Value{value: C.value_array_key_get(v.value, str, C.size_t(len(key)))}
 			C.free(unsafe.Pointer(str))
 
 			val[key] = t.Interface()
@@ -265,6 +585,140 @@ func (v *Value) Map() map[string]interface{} {
 	return val
 }
 
+// CallMethod calls the method named on the internal PHP object, with arguments
+// converted to PHP values in the same way as for NewValue, and returns a Value
+// containing the method's return value. The Value returned is owned by the
+// caller, and should be destroyed once no longer in use. Errors raised during
+// the call, including uncaught exceptions, are returned in the same way as for
+// Context.Exec.
+func (v *Value) CallMethod(name string, args ...interface{}) (*Value, error) {
+	if v.Kind() != Object {
+		return nil, fmt.Errorf("Cannot call method '%s' on non-object value", name)
+	}
+
+	return call(v, name, args)
+}
+
+// ClassName returns the class name for the internal PHP object, or an empty
+// string if the value is not an object.
+func (v *Value) ClassName() string {
+	name, err := C.value_object_class_name(v.value)
+	if err != nil {
+		return ""
+	}
+
+	defer C.free(unsafe.Pointer(name))
+
+	return C.GoString(name)
+}
+
+// InstanceOf returns whether the internal PHP object is an instance of the
+// class or interface named, or any of its subclasses. False is returned for
+// values that are not objects.
+func (v *Value) InstanceOf(class string) bool {
+	n := C.CString(class)
+	defer C.free(unsafe.Pointer(n))
+
+	return bool(C.value_object_instance_of(v.value, n))
+}
+
+// Property returns a Value containing the property named of the internal PHP
+// object, regardless of its visibility. Properties not set on the object are
+// returned as null values. The Value returned is owned by the caller, and
+// should be destroyed once no longer in use.
+func (v *Value) Property(name string) (*Value, error) {
+	if v.Kind() != Object {
+		return nil, fmt.Errorf("Cannot get property '%s' of non-object value", name)
+	}
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	c := currentContext()
+	if c != nil {
+		c.err = nil
+	}
+
+	result, err := C.value_object_property_get(v.value, n)
+	if c != nil && c.err != nil {
+		return nil, c.err
+	} else if err != nil {
+		return nil, fmt.Errorf("Error getting property '%s' of object value", name)
+	}
+
+	return &Value{value: result}, nil
+}
+
+// SetProperty sets the property named of the internal PHP object to the value
+// given, regardless of the property's visibility. The value is converted in the
+// same way as for NewValue.
+func (v *Value) SetProperty(name string, val interface{}) error {
+	if v.Kind() != Object {
+		return fmt.Errorf("Cannot set property '%s' of non-object value", name)
+	}
+
+	p, err := NewValue(val)
+	if err != nil {
+		return err
+	}
+
+	defer p.Destroy()
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	c := currentContext()
+	if c != nil {
+		c.err = nil
+	}
+
+	if _, err := C.value_object_property_update(v.value, n, p.value); c != nil && c.err != nil {
+		return c.err
+	} else if err != nil {
+		return fmt.Errorf("Error setting property '%s' of object value", name)
+	}
+
+	return nil
+}
+
+// Call calls the PHP function named or, if obj is not nil, the method named on
+// obj, and returns the call's return value. Arguments are converted to PHP
+// values as per NewValue, and errors raised are reported by the active context.
+func call(obj *Value, name string, args []interface{}) (*Value, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	a, err := NewValue(args)
+	if err != nil {
+		return nil, err
+	}
+
+	defer a.Destroy()
+
+	n := C.CString(name)
+	defer C.free(unsafe.Pointer(n))
+
+	var ptr *C.struct__engine_value
+	if obj != nil {
+		ptr = obj.value
+	}
+
+	c := currentContext()
+	if c != nil {
+		c.err = nil
+	}
+
+	result, err := C.value_call(ptr, n, a.value)
+	if c != nil && c.err != nil {
+		return nil, c.err
+	} else if err != nil {
+		return nil, fmt.Errorf("Error calling function '%s'", name)
+	}
+
+	return &Value{value: result}, nil
+}
+
 // Ptr returns a pointer to the internal PHP value, and is mostly used for
 // passing to C functions.
 func (v *Value) Ptr() unsafe.Pointer {
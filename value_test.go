@@ -5,6 +5,8 @@
 package php
 
 import (
+	"fmt"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -38,6 +40,14 @@ var valueNewTests = []struct {
 		"Hello World",
 		"Hello World",
 	},
+	{
+		"Hello\x00World",
+		"Hello\x00World",
+	},
+	{
+		[]byte("Hello\x00World"),
+		"Hello\x00World",
+	},
 	{
 		[]string{"Knick", "Knack"},
 		[]interface{}{"Knick", "Knack"},
@@ -54,6 +64,10 @@ var valueNewTests = []struct {
 		map[int]string{10: "this", 20: "that"},
 		map[string]interface{}{"10": "this", "20": "that"},
 	},
+	{
+		map[string]string{"a\x00b": "c\x00d"},
+		map[string]interface{}{"a\x00b": "c\x00d"},
+	},
 	{
 		struct {
 			I int
@@ -63,6 +77,93 @@ var valueNewTests = []struct {
 		}{66, "wow", true, "hidden"},
 		map[string]interface{}{"I": int64(66), "S": "wow", "B": true},
 	},
+	{
+		struct {
+			ID      int    `php:"user_id"`
+			Name    string `php:",omitempty"`
+			Email   string `php:"email,omitempty"`
+			Ignored bool   `php:"-"`
+		}{1, "", "a@b.c", true},
+		map[string]interface{}{"user_id": int64(1), "email": "a@b.c"},
+	},
+	{
+		struct {
+			Inner struct {
+				A int
+				B int `php:"b"`
+			} `php:",inline"`
+			C int
+		}{struct {
+			A int
+			B int `php:"b"`
+		}{1, 2}, 3},
+		map[string]interface{}{"A": int64(1), "b": int64(2), "C": int64(3)},
+	},
+	{
+		uint8(255),
+		int64(255),
+	},
+	{
+		uint64(math.MaxUint64),
+		float64(math.MaxUint64),
+	},
+	{
+		func(n int) *int { return &n }(42),
+		int64(42),
+	},
+	{
+		(*int)(nil),
+		nil,
+	},
+	{
+		&struct{ A int }{1},
+		map[string]interface{}{"A": int64(1)},
+	},
+	{
+		[2]string{"Knick", "Knack"},
+		[]interface{}{"Knick", "Knack"},
+	},
+	{
+		[]interface{}{uint(2), nil},
+		[]interface{}{int64(2), nil},
+	},
+	{
+		map[int64]string{10: "this", 20: "that"},
+		map[string]interface{}{"10": "this", "20": "that"},
+	},
+	{
+		map[uint64]string{1: "one", math.MaxUint64: "max"},
+		map[string]interface{}{"1": "one", "18446744073709551615": "max"},
+	},
+	{
+		testID(42),
+		"id-42",
+	},
+	{
+		[]testID{1, 2},
+		[]interface{}{"id-1", "id-2"},
+	},
+}
+
+// A type implementing Marshaler and Unmarshaler, represented in PHP as a string.
+type testID int
+
+func (id testID) MarshalPHP() (interface{}, error) {
+	if id < 0 {
+		return nil, fmt.Errorf("Invalid ID %d", int(id))
+	}
+
+	return fmt.Sprintf("id-%d", int(id)), nil
+}
+
+func (id *testID) UnmarshalPHP(val interface{}) error {
+	s, ok := val.(string)
+	if !ok {
+		return fmt.Errorf("Invalid ID '%v'", val)
+	}
+
+	_, err := fmt.Sscanf(s, "id-%d", (*int)(id))
+	return err
 }
 
 func TestValueNew(t *testing.T) {
@@ -93,15 +194,20 @@ func TestValueNew(t *testing.T) {
 }
 
 var valueNewInvalidTests = []interface{}{
-	uint(10),
 	make(chan int),
 	func() {},
-	[]interface{}{uint(2)},
+	complex(1, 2),
+	[]interface{}{func() {}},
+	map[float64]interface{}{1.5: true},
 	map[string]interface{}{"t": make(chan bool)},
 	map[bool]interface{}{false: true},
 	struct {
 		T interface{}
 	}{func() {}},
+	struct {
+		T int `php:",inline"`
+	}{1},
+	testID(-1),
 }
 
 func TestValueNewInvalid(t *testing.T) {
@@ -118,6 +224,67 @@ func TestValueNewInvalid(t *testing.T) {
 	c.Destroy()
 }
 
+type testNode struct {
+	Name string
+	Next *testNode
+}
+
+type testInlineNode struct {
+	Name string
+	Next *testInlineNode `php:",inline"`
+}
+
+type testSelf struct {
+	Name string
+}
+
+func (s testSelf) MarshalPHP() (interface{}, error) {
+	return s, nil
+}
+
+func TestValueNewCyclic(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	node := &testNode{Name: "node"}
+	node.Next = node
+
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	s := []interface{}{nil}
+	s[0] = s
+
+	inline := &testInlineNode{Name: "inline"}
+	inline.Next = inline
+
+	cyclic := map[string]interface{}{
+		"pointer":   node,
+		"map":       m,
+		"slice":     s,
+		"marshaler": testSelf{"self"},
+		"inline":    *inline,
+	}
+
+	for name, value := range cyclic {
+		val, err := NewValue(value)
+		if err == nil {
+			val.Destroy()
+			t.Errorf("NewValue(): Cyclic %s value is invalid but no error occured", name)
+		}
+	}
+
+	// Values referenced more than once without forming a cycle are valid.
+	shared := &testNode{Name: "shared"}
+
+	val, err := NewValue([]*testNode{shared, {Name: "outer", Next: shared}})
+	if err != nil {
+		t.Fatalf("NewValue(): %s", err)
+	}
+
+	val.Destroy()
+}
+
 var valueKindTests = []struct {
 	value    interface{}
 	expected ValueKind
@@ -374,6 +541,10 @@ var valueStringTests = []struct {
 		"Hello World",
 		"Hello World",
 	},
+	{
+		"Hello\x00World",
+		"Hello\x00World",
+	},
 	{
 		[]string{"Knick", "Knack"},
 		"Array",
@@ -413,6 +584,46 @@ func TestValueString(t *testing.T) {
 	c.Destroy()
 }
 
+var valueBytesTests = []struct {
+	value    interface{}
+	expected []byte
+}{
+	{
+		42,
+		[]byte("42"),
+	},
+	{
+		"Hello World",
+		[]byte("Hello World"),
+	},
+	{
+		[]byte{0xde, 0xad, 0x00, 0xbe, 0xef},
+		[]byte{0xde, 0xad, 0x00, 0xbe, 0xef},
+	},
+}
+
+func TestValueBytes(t *testing.T) {
+	c, _ := e.NewContext()
+
+	for _, tt := range valueBytesTests {
+		val, err := NewValue(tt.value)
+		if err != nil {
+			t.Errorf("NewValue('%v'): %s", tt.value, err)
+			continue
+		}
+
+		actual := val.Bytes()
+
+		if reflect.DeepEqual(actual, tt.expected) == false {
+			t.Errorf("Value.Bytes('%v'): expected '%#v', actual '%#v'", tt.value, tt.expected, actual)
+		}
+
+		val.Destroy()
+	}
+
+	c.Destroy()
+}
+
 var valueSliceTests = []struct {
 	value    interface{}
 	expected interface{}
@@ -531,6 +742,85 @@ func TestValueMap(t *testing.T) {
 	c.Destroy()
 }
 
+func TestValueObject(t *testing.T) {
+	c, _ := e.NewContext()
+	defer c.Destroy()
+
+	script := `class Counter {
+		public $name = 'counter';
+		private $count = 0;
+		public function add($n) { $this->count += $n; return $this->count; }
+		public function fail() { throw new LogicException('Failed'); }
+	}
+	return new Counter;`
+
+	obj, err := c.Eval(script)
+	if err != nil {
+		t.Fatalf("Context.Eval(): %s", err)
+	}
+
+	for i, n := range []int{1, 2, 3} {
+		val, err := obj.CallMethod("add", n)
+		if err != nil {
+			t.Fatalf("Value.CallMethod('add'): %s", err)
+		}
+
+		// State is preserved between calls on the same object.
+		if expected := int64((i + 1) * (i + 2) / 2); val.Int() != expected {
+			t.Errorf("Value.CallMethod('add'): Expected '%d', actual '%d'", expected, val.Int())
+		}
+
+		val.Destroy()
+	}
+
+	if err := obj.SetProperty("name", "updated"); err != nil {
+		t.Fatalf("Value.SetProperty('name'): %s", err)
+	}
+
+	if err := obj.SetProperty("count", 10); err != nil {
+		t.Fatalf("Value.SetProperty('count'): %s", err)
+	}
+
+	for name, expected := range map[string]interface{}{"name": "updated", "count": int64(10), "missing": nil} {
+		val, err := obj.Property(name)
+		if err != nil {
+			t.Errorf("Value.Property('%s'): %s", name, err)
+			continue
+		}
+
+		if actual := val.Interface(); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Value.Property('%s'): Expected '%#v', actual '%#v'", name, expected, actual)
+		}
+
+		val.Destroy()
+	}
+
+	if _, err := obj.CallMethod("fail"); err == nil {
+		t.Errorf("Value.CallMethod('fail'): Expected error, got none")
+	} else if ex, ok := err.(*Exception); !ok || ex.Class != "LogicException" {
+		t.Errorf("Value.CallMethod('fail'): Expected *Exception, actual '%#v'", err)
+	}
+
+	if _, err := obj.CallMethod("missing"); err == nil {
+		t.Errorf("Value.CallMethod('missing'): Expected error, got none")
+	}
+
+	val, _ := NewValue(42)
+	defer val.Destroy()
+
+	if _, err := val.CallMethod("add", 1); err == nil {
+		t.Errorf("Value.CallMethod('add'): Expected error for non-object value, got none")
+	}
+
+	if _, err := val.Property("name"); err == nil {
+		t.Errorf("Value.Property('name'): Expected error for non-object value, got none")
+	}
+
+	if err := val.SetProperty("name", "value"); err == nil {
+		t.Errorf("Value.SetProperty('name'): Expected error for non-object value, got none")
+	}
+}
+
 func TestValuePtr(t *testing.T) {
 	c, _ := e.NewContext()
 	defer c.Destroy()
@@ -0,0 +1,121 @@
+// Copyright 2017 Alexander Palaistras. All rights reserved.
+// Use of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package php
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// The environment variable used for signalling worker processes, set to the
+// kind of worker the process is started as.
+const workerEnv = "GOPHP_WORKER"
+
+// Kinds of worker processes, as set in the worker environment variable.
+const (
+	poolWorkerKind     = "pool"
+	isolatedWorkerKind = "isolated"
+)
+
+// ServeWorker runs the calling process as a worker process, if the process was
+// started as such, and otherwise returns immediately. Worker processes are
+// started by running the program's executable, without any arguments, for
+// isolated engines, as created by NewIsolated, and for process-based pools, as
+// created by NewPool for non thread-safe builds of PHP. Programs using these
+// are therefore required to call ServeWorker at the start of their main
+// function (or TestMain function, for tests), before any other work is done;
+// the call never returns for worker processes.
+//
+// The engine used by the worker process is created by calling start with the
+// configuration the parent engine was created with, or by NewWithConfig if
+// start is nil. Modules cannot be passed on to worker processes, and are not
+// set in the configuration given; start is expected to set these, and to make
+// any definitions (such as those made by Engine.Define or Engine.DefineFunc)
+// made for the parent engine, as required by scripts executed by workers.
+func ServeWorker(start func(config Config) (*Engine, error)) {
+	kind := os.Getenv(workerEnv)
+	if kind == "" {
+		return
+	}
+
+	// Any workers started by the worker process are not workers of the parent.
+	os.Unsetenv(workerEnv)
+
+	if start == nil {
+		start = NewWithConfig
+	}
+
+	switch kind {
+	case poolWorkerKind:
+		os.Exit(servePoolWorker(start, os.NewFile(3, "jobs"), os.NewFile(4, "results")))
+	case isolatedWorkerKind:
+		conn, err := net.FileConn(os.NewFile(3, "isolated-engine"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to connect to isolated engine: %s\n", err)
+			os.Exit(1)
+		}
+
+		os.Exit(serveIsolatedWorker(start, conn))
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown kind of worker process '%s'\n", kind)
+	os.Exit(1)
+}
+
+// CheckWorker returns an error if the running process was started as a worker
+// process, but has not called ServeWorker, in which case any worker processes
+// started would in turn do the same indefinitely.
+func checkWorker() error {
+	if os.Getenv(workerEnv) != "" {
+		return fmt.Errorf("Cannot start worker processes from worker process not calling ServeWorker")
+	}
+
+	return nil
+}
+
+// WorkerConfig represents the configuration for the engine used by a worker
+// process, as passed on by the parent process. Modules are not included, as
+// these cannot be transferred between processes.
+type workerConfig struct {
+	IniFile        string
+	Ini            map[string]string
+	Extensions     []string
+	ZendExtensions []string
+}
+
+// NewWorkerConfig returns the worker configuration for the engine configuration
+// given.
+func newWorkerConfig(config Config) *workerConfig {
+	return &workerConfig{
+		IniFile:        config.IniFile,
+		Ini:            config.Ini,
+		Extensions:     config.Extensions,
+		ZendExtensions: config.ZendExtensions,
+	}
+}
+
+// Config returns the engine configuration for the worker configuration.
+func (c *workerConfig) config() Config {
+	return Config{
+		IniFile:        c.IniFile,
+		Ini:            c.Ini,
+		Extensions:     c.Extensions,
+		ZendExtensions: c.ZendExtensions,
+	}
+}
+
+// StartWorker creates the engine for a worker process from the configuration
+// given, returning an error if the engine fails to start.
+func startWorker(start func(config Config) (*Engine, error), config *workerConfig) (*Engine, error) {
+	e, err := start(config.config())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start worker engine: %s", err)
+	} else if e == nil || e.engine == nil {
+		return nil, fmt.Errorf("Unable to start worker engine: Engine is inactive")
+	}
+
+	return e, nil
+}